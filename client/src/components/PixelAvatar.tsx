@@ -6,13 +6,12 @@ interface PixelAvatarProps {
   size?: number;
 }
 
-const AvatarContainer = styled.div<{ size: number }>`
+const AvatarContainer = styled.div<{ size: number; grid: number }>`
   width: ${props => props.size}px;
   height: ${props => props.size}px;
   display: grid;
-  grid-template-columns: repeat(4, 1fr);
-  grid-template-rows: repeat(4, 1fr);
-  gap: 1px;
+  grid-template-columns: repeat(${props => props.grid}, 1fr);
+  grid-template-rows: repeat(${props => props.grid}, 1fr);
   image-rendering: pixelated;
   image-rendering: -moz-crisp-edges;
   image-rendering: crisp-edges;
@@ -25,21 +24,35 @@ const PixelDot = styled.div<{ color: string }>`
   border-radius: 0;
 `;
 
+// 与服务端 internal/avatar 调色板保持一致，0为透明
 const colorMap: { [key: string]: string } = {
-  '0': '#000000', // 黑色
-  '1': '#00ff41', // 绿色
-  '2': '#00d9ff', // 蓝色
-  '3': '#ff006e', // 粉色
-  '4': '#8338ec', // 紫色
-  '5': '#ffbe0b', // 黄色
+  '0': 'transparent',
+  '1': '#0D1117',
+  '2': '#161B22',
+  '3': '#58A6FF',
+  '4': '#3FB950',
+  '5': '#F85149',
+  '6': '#C9D1D9',
+  '7': '#E6EDF3',
+  '8': '#FF6B6B',
+  '9': '#4ECDC4',
+  'A': '#FFE66D',
+  'B': '#A8DADC',
+  'C': '#F4A261',
+  'D': '#9B5DE5',
+  'E': '#6E7681',
+  'F': '#000000',
 };
 
 const PixelAvatar: React.FC<PixelAvatarProps> = ({ avatar, size = 24 }) => {
+  // 头像编码为8x8（64位）或16x16（256位）的16进制字符
+  const grid = avatar.length === 256 ? 16 : 8;
+
   const renderPixels = () => {
     const pixels = [];
-    for (let i = 0; i < 16; i++) {
-      const colorIndex = avatar[i] || '0';
-      const color = colorMap[colorIndex] || '#000000';
+    for (let i = 0; i < grid * grid; i++) {
+      const colorIndex = (avatar[i] || '0').toUpperCase();
+      const color = colorMap[colorIndex] || 'transparent';
       pixels.push(
         <PixelDot key={i} color={color} />
       );
@@ -48,7 +61,7 @@ const PixelAvatar: React.FC<PixelAvatarProps> = ({ avatar, size = 24 }) => {
   };
 
   return (
-    <AvatarContainer size={size}>
+    <AvatarContainer size={size} grid={grid}>
       {renderPixels()}
     </AvatarContainer>
   );
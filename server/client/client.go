@@ -0,0 +1,547 @@
+// Package client 聊天室WebSocket协议的Go客户端，供机器人、集成和测试使用
+//
+// 客户端负责握手、加入聊天室、收发事件、心跳检测和断线重连。重连时携带会话令牌和
+// 最后收到的房间事件序号，服务端据此恢复用户身份并补发错过的事件。
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion 客户端实现的协议版本，握手时服务端不支持该版本则连接失败
+const ProtocolVersion = 2
+
+const (
+	defaultPingInterval = 25 * time.Second
+	defaultPongTimeout  = 10 * time.Second
+	defaultMinBackoff   = 500 * time.Millisecond
+	defaultMaxBackoff   = 30 * time.Second
+	defaultEventBuffer  = 256
+
+	// writeWait 写入一帧的超时时间
+	writeWait = 10 * time.Second
+
+	// outboundBuffer 等待发送的帧数，断线期间发送的帧在重连后发出
+	outboundBuffer = 64
+
+	// codeUnsupportedVersion 服务端不支持客户端协议版本时的错误码
+	codeUnsupportedVersion = "UNSUPPORTED_VERSION"
+)
+
+var (
+	// ErrClosed 客户端已关闭
+	ErrClosed = errors.New("客户端已关闭")
+
+	// ErrConnectionLost 请求已发出但等待回复期间连接断开，请求可能已被服务端处理
+	ErrConnectionLost = errors.New("连接已断开")
+
+	// ErrUnsupportedVersion 服务端不支持客户端的协议版本
+	ErrUnsupportedVersion = errors.New("服务端不支持客户端的协议版本")
+)
+
+// Options 客户端配置
+type Options struct {
+	URL        string // 服务端WebSocket地址，如 ws://localhost:3001/ws
+	Nickname   string // 昵称，可写作 nickname#secret 以获得tripcode
+	BotToken   string // 机器人令牌，设置时以机器人身份加入，忽略Nickname
+	AdminToken string // 管理员令牌
+	Locale     string // 错误信息语言，zh-CN（默认）或 en
+
+	Header http.Header       // 握手时附带的请求头，如Origin
+	Dialer *websocket.Dialer // 为空时使用websocket.DefaultDialer
+
+	PingInterval time.Duration // 发送ping的间隔，默认25秒
+	PongTimeout  time.Duration // 超过PingInterval+PongTimeout未收到任何消息时视为断线，默认10秒
+	MinBackoff   time.Duration // 首次重连的等待时间，之后每次翻倍，默认0.5秒
+	MaxBackoff   time.Duration // 重连等待时间的上限，默认30秒
+	EventBuffer  int           // 事件通道的缓冲大小，默认256
+}
+
+// withDefaults 填充未设置的配置项
+func (o Options) withDefaults() Options {
+	if o.Dialer == nil {
+		o.Dialer = websocket.DefaultDialer
+	}
+	if o.PingInterval <= 0 {
+		o.PingInterval = defaultPingInterval
+	}
+	if o.PongTimeout <= 0 {
+		o.PongTimeout = defaultPongTimeout
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = defaultMinBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = max(defaultMaxBackoff, o.MinBackoff)
+	}
+	if o.EventBuffer <= 0 {
+		o.EventBuffer = defaultEventBuffer
+	}
+	return o
+}
+
+// reply Call请求的回复
+type reply struct {
+	event Event
+	err   error
+}
+
+// pendingCall 等待回复的Call，sent表示请求帧已写入连接
+type pendingCall struct {
+	replies chan reply
+	sent    bool
+}
+
+// outboundFrame 等待发送的帧，Call的帧带有请求ID
+type outboundFrame struct {
+	data      []byte
+	requestID string
+}
+
+// Client 聊天室客户端，所有方法可以并发调用
+type Client struct {
+	options  Options
+	events   chan Event
+	outbound chan outboundFrame
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	nextID   atomic.Uint64
+
+	mu           sync.Mutex
+	user         *User
+	sessionToken string
+	lastSeq      uint64
+	pending      map[string]*pendingCall // 请求ID -> 等待回复的Call
+}
+
+// Dial 连接服务端并加入聊天室，加入成功后返回，之后断线会自动重连
+// 加入响应作为第一个joined事件放入事件通道
+func Dial(ctx context.Context, options Options) (*Client, error) {
+	c := &Client{
+		options:  options.withDefaults(),
+		outbound: make(chan outboundFrame, outboundBuffer),
+		done:     make(chan struct{}),
+		pending:  make(map[string]*pendingCall),
+	}
+	c.events = make(chan Event, c.options.EventBuffer)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	conn, joined, err := c.connect(ctx)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	c.events <- joined
+
+	go c.run(conn)
+	return c, nil
+}
+
+// Events 收到的事件，客户端关闭后通道关闭
+// 需要持续读取，读取过慢时服务端会断开连接，重连后补发错过的房间事件
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// User 当前用户，重连后可能变化
+func (c *Client) User() *User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.user
+}
+
+// LastSeq 最后收到的房间事件序号
+func (c *Client) LastSeq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+// Send 发送事件，不等待回复；断线期间发送的事件在重连后发出
+func (c *Client) Send(ctx context.Context, eventType string, data interface{}) error {
+	frame, err := json.Marshal(request{Type: eventType, Data: data})
+	if err != nil {
+		return err
+	}
+	return c.enqueue(ctx, outboundFrame{data: frame})
+}
+
+// Call 发送事件并等待服务端的直接回复，服务端返回error或nack时返回*ServerError
+// 只适用于有直接回复的事件，如 send_message、get_thread、mark_read；edit_message等只广播的事件不会回复，应使用Send
+// 断线时尚未发出的请求在重连后发出并继续等待，已发出的请求返回ErrConnectionLost；
+// ctx取消后尚未发出的请求不会再发送
+func (c *Client) Call(ctx context.Context, eventType string, data interface{}) (Event, error) {
+	id := strconv.FormatUint(c.nextID.Add(1), 10)
+	frame, err := json.Marshal(request{Type: eventType, Data: data, RequestID: id})
+	if err != nil {
+		return Event{}, err
+	}
+
+	call := &pendingCall{replies: make(chan reply, 1)}
+	c.mu.Lock()
+	c.pending[id] = call
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.enqueue(ctx, outboundFrame{data: frame, requestID: id}); err != nil {
+		return Event{}, err
+	}
+
+	select {
+	case r := <-call.replies:
+		if r.err != nil {
+			return Event{}, r.err
+		}
+		return r.event, errorOf(r.event)
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	case <-c.ctx.Done():
+		return Event{}, ErrClosed
+	}
+}
+
+// SendMessage 发送消息并等待确认
+func (c *Client) SendMessage(ctx context.Context, req *SendMessageRequest) (*AckEvent, error) {
+	event, err := c.Call(ctx, "send_message", req)
+	if err != nil {
+		return nil, err
+	}
+	ack, ok := event.Data.(*AckEvent)
+	if !ok {
+		return nil, errors.New("意外的回复: " + event.Type)
+	}
+	return ack, nil
+}
+
+// SendText 发送文本消息并等待确认
+func (c *Client) SendText(ctx context.Context, content string) (*AckEvent, error) {
+	return c.SendMessage(ctx, &SendMessageRequest{Content: content})
+}
+
+// Close 离开聊天室并关闭连接，不再重连
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// request 发送的消息外层结构，与 models.WebSocketMessage 对应
+type request struct {
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// enqueue 将帧放入发送队列
+func (c *Client) enqueue(ctx context.Context, frame outboundFrame) error {
+	select {
+	case c.outbound <- frame:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// run 维持连接，断线后按指数退避重连，直到客户端关闭
+func (c *Client) run(conn *websocket.Conn) {
+	defer close(c.done)
+	defer close(c.events)
+
+	for {
+		err := c.serve(conn)
+		c.failPending()
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		for attempt := 0; ; attempt++ {
+			delay := c.backoff(attempt)
+			if !c.emit(Event{Type: EventDisconnected, Data: &DisconnectedEvent{Err: err, RetryIn: delay}}) {
+				return
+			}
+			select {
+			case <-time.After(delay):
+			case <-c.ctx.Done():
+				return
+			}
+
+			var joined Event
+			conn, joined, err = c.connect(c.ctx)
+			if err == nil {
+				if !c.emit(joined) {
+					conn.Close()
+					return
+				}
+				break
+			}
+			if c.ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// connect 建立连接、握手并加入聊天室，返回joined事件
+func (c *Client) connect(ctx context.Context) (*websocket.Conn, Event, error) {
+	conn, _, err := c.options.Dialer.DialContext(ctx, c.options.URL, c.options.Header)
+	if err != nil {
+		return nil, Event{}, err
+	}
+
+	// 握手和加入阶段的超时跟随ctx，但不超过一个心跳周期
+	deadline := time.Now().Add(c.options.PingInterval + c.options.PongTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := c.hello(conn); err != nil {
+		conn.Close()
+		return nil, Event{}, err
+	}
+
+	c.mu.Lock()
+	joinReq := &JoinRequest{
+		Nickname:     c.options.Nickname,
+		BotToken:     c.options.BotToken,
+		AdminToken:   c.options.AdminToken,
+		SessionToken: c.sessionToken,
+		LastSeq:      c.lastSeq,
+	}
+	c.mu.Unlock()
+
+	joined, err := c.handshake(conn, "join", joinReq)
+	if err != nil {
+		conn.Close()
+		return nil, Event{}, err
+	}
+	response := joined.Data.(*JoinResponse)
+
+	c.mu.Lock()
+	c.user = response.User
+	c.sessionToken = response.SessionToken
+	if c.lastSeq == 0 {
+		c.lastSeq = response.Seq
+	}
+	c.mu.Unlock()
+	return conn, joined, nil
+}
+
+// hello 协商协议版本和错误信息语言，服务端不支持ProtocolVersion时返回ErrUnsupportedVersion
+func (c *Client) hello(conn *websocket.Conn) error {
+	event, err := c.handshake(conn, EventHello, &HelloRequest{
+		Versions: []int{ProtocolVersion},
+		Locale:   c.options.Locale,
+	})
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) && serverErr.Code == codeUnsupportedVersion {
+		return ErrUnsupportedVersion
+	}
+	if err != nil {
+		return err
+	}
+	if response := event.Data.(*HelloResponse); response.Version != ProtocolVersion {
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+// handshake 在连接建立阶段发送请求并等待对应的回复
+func (c *Client) handshake(conn *websocket.Conn, eventType string, data interface{}) (Event, error) {
+	id := "connect-" + strconv.FormatUint(c.nextID.Add(1), 10)
+	frame, err := json.Marshal(request{Type: eventType, Data: data, RequestID: id})
+	if err != nil {
+		return Event{}, err
+	}
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+		return Event{}, err
+	}
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return Event{}, err
+		}
+		event, err := decodeEvent(frame)
+		if err != nil || event.RequestID != id {
+			continue
+		}
+		if err := errorOf(event); err != nil {
+			return Event{}, err
+		}
+		switch event.Type {
+		case EventHello:
+			if _, ok := event.Data.(*HelloResponse); !ok {
+				return Event{}, errors.New("无效的握手响应")
+			}
+		case EventJoined:
+			if _, ok := event.Data.(*JoinResponse); !ok {
+				return Event{}, errors.New("无效的加入响应")
+			}
+		}
+		return event, nil
+	}
+}
+
+// serve 在一个连接上收发消息，连接断开或客户端关闭时返回
+func (c *Client) serve(conn *websocket.Conn) error {
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- c.readLoop(conn)
+	}()
+	defer func() {
+		conn.Close()
+		<-readErr
+	}()
+
+	ping, err := json.Marshal(request{Type: "ping"})
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(c.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var frame []byte
+		select {
+		case out := <-c.outbound:
+			if !c.markSent(out.requestID) {
+				continue
+			}
+			frame = out.data
+		case <-ticker.C:
+			frame = ping
+		case err := <-readErr:
+			readErr <- err
+			return err
+		case <-c.ctx.Done():
+			c.leave(conn)
+			return c.ctx.Err()
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// leave 发送leave并关闭连接，服务端立即广播用户离开
+func (c *Client) leave(conn *websocket.Conn) {
+	if frame, err := json.Marshal(request{Type: "leave"}); err == nil {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		conn.WriteMessage(websocket.TextMessage, frame)
+	}
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+}
+
+// readLoop 读取并分发事件，超过一个心跳周期没有收到任何消息时返回超时错误
+func (c *Client) readLoop(conn *websocket.Conn) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.options.PingInterval + c.options.PongTimeout))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		event, err := decodeEvent(frame)
+		if err != nil || event.Type == EventPong {
+			continue
+		}
+		if !c.dispatch(event) {
+			return ErrClosed
+		}
+	}
+}
+
+// dispatch 记录事件序号，Call的回复交给等待的调用方，其他事件放入事件通道
+func (c *Client) dispatch(event Event) bool {
+	c.mu.Lock()
+	if event.Seq > c.lastSeq {
+		c.lastSeq = event.Seq
+	}
+	// 无法补发时（如服务端重启后序号重新开始）从服务端当前的序号继续
+	if resync, ok := event.Data.(*ResyncRequiredEvent); ok {
+		c.lastSeq = resync.CurrentSeq
+	}
+	call, waiting := c.pending[event.RequestID]
+	if waiting {
+		delete(c.pending, event.RequestID)
+	}
+	c.mu.Unlock()
+
+	if waiting {
+		call.replies <- reply{event: event}
+		return true
+	}
+	return c.emit(event)
+}
+
+// emit 将事件放入事件通道，客户端关闭时返回false
+func (c *Client) emit(event Event) bool {
+	select {
+	case c.events <- event:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// markSent 记录Call的请求帧即将写入连接，Call已超时或取消时返回false，不再发送
+func (c *Client) markSent(requestID string) bool {
+	if requestID == "" {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call, waiting := c.pending[requestID]
+	if waiting {
+		call.sent = true
+	}
+	return waiting
+}
+
+// failPending 连接断开时通知请求已发出的Call，尚未发出的请求留在队列中重连后发送
+func (c *Client) failPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, call := range c.pending {
+		if call.sent {
+			call.replies <- reply{err: ErrConnectionLost}
+			delete(c.pending, id)
+		}
+	}
+}
+
+// backoff 第attempt次重连前的等待时间，加入随机抖动避免大量客户端同时重连
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.options.MinBackoff
+	for i := 0; i < attempt && delay < c.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.options.MaxBackoff)
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,331 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pixel-chat-server/internal/services"
+	ws "pixel-chat-server/internal/websocket"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// testServer 运行真实Hub和聊天服务的测试服务端，可以断开现有连接并暂时拒绝新连接
+type testServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	conns   []*websocket.Conn
+	refuse  bool
+	upgrade websocket.Upgrader
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	uploadService, err := services.NewUploadService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outgoing, err := services.NewOutgoingWebhookService(services.OutgoingWebhookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatService := services.NewChatService(
+		services.NewUserService(),
+		services.NewMessageService(),
+		services.NewTripcodeService("test-pepper"),
+		services.NewMentionService(),
+		uploadService,
+		services.NewReadMarkerService(),
+		services.NewRoomService(),
+		services.NewAPITokenService(),
+		services.NewIncomingWebhookService(),
+		outgoing,
+		services.NewBotService(),
+		services.ChatOptions{},
+	)
+	hub := ws.NewHubWith(chatService, ws.HubOptions{})
+	go hub.Run()
+
+	s := &testServer{upgrade: websocket.Upgrader{Subprotocols: ws.Subprotocols()}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		refuse := s.refuse
+		s.mu.Unlock()
+		if refuse {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := s.upgrade.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		hub.HandleWebSocket(conn, uuid.New().String())
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// dropConnections 断开所有连接，refuse为true时拒绝之后的连接直到调用accept
+func (s *testServer) dropConnections(refuse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = refuse
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// accept 重新接受连接
+func (s *testServer) accept() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = false
+}
+
+// dial 以指定昵称连接测试服务端
+func (s *testServer) dial(t *testing.T, nickname string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, Options{
+		URL:        "ws" + strings.TrimPrefix(s.URL, "http"),
+		Nickname:   nickname,
+		MinBackoff: 20 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor 读取事件直到match返回true，跳过的事件交给skipped
+func waitFor(t *testing.T, c *Client, match func(Event) bool, skipped ...func(Event)) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-c.Events():
+			if !ok {
+				t.Fatal("event channel closed")
+			}
+			if match(event) {
+				return event
+			}
+			for _, skip := range skipped {
+				skip(event)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+// isType 匹配指定类型的事件
+func isType(eventType string) func(Event) bool {
+	return func(event Event) bool {
+		return event.Type == eventType
+	}
+}
+
+// isMessage 匹配指定内容的新消息
+func isMessage(content string) func(Event) bool {
+	return func(event Event) bool {
+		data, ok := event.Data.(*NewMessageEvent)
+		return ok && data.Message.Content == content
+	}
+}
+
+func TestClientJoin(t *testing.T) {
+	server := newTestServer(t)
+	c := server.dial(t, "alice")
+
+	joined := waitFor(t, c, isType(EventJoined))
+	response := joined.Data.(*JoinResponse)
+	if response.User == nil || response.User.Nickname != "alice" || response.SessionToken == "" {
+		t.Fatalf("joined = %+v, want alice with a session token", response)
+	}
+	if c.User() == nil || c.User().ID != response.User.ID {
+		t.Errorf("User() = %+v, want %s", c.User(), response.User.ID)
+	}
+	if c.LastSeq() != response.Seq {
+		t.Errorf("LastSeq() = %d, want joined seq %d", c.LastSeq(), response.Seq)
+	}
+}
+
+func TestClientSendText(t *testing.T) {
+	server := newTestServer(t)
+	c := server.dial(t, "alice")
+	waitFor(t, c, isType(EventJoined))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ack, err := c.SendText(ctx, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.MessageID == "" || ack.Duplicate {
+		t.Fatalf("ack = %+v, want a new message", ack)
+	}
+	event := waitFor(t, c, isMessage("hello"))
+	if id := event.Data.(*NewMessageEvent).Message.ID; id != ack.MessageID {
+		t.Errorf("broadcast message id = %s, want %s", id, ack.MessageID)
+	}
+
+	// 携带client_id重发时服务端回复首次发送的结果
+	first, err := c.SendMessage(ctx, &SendMessageRequest{Content: "once", ClientID: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.SendMessage(ctx, &SendMessageRequest{Content: "once", ClientID: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Duplicate || again.MessageID != first.MessageID {
+		t.Errorf("resend ack = %+v, want duplicate of %s", again, first.MessageID)
+	}
+
+	// 失败时nack和error都转换为ServerError
+	for _, req := range []*SendMessageRequest{{Content: "", ClientID: "c2"}, {Content: ""}} {
+		_, err := c.SendMessage(ctx, req)
+		var serverErr *ServerError
+		if !errors.As(err, &serverErr) || serverErr.Code != services.CodeMessageEmpty {
+			t.Errorf("send %+v: err = %v, want %s", req, err, services.CodeMessageEmpty)
+		}
+	}
+}
+
+func TestClientReconnectReplaysMissedEvents(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.dial(t, "alice")
+	bob := server.dial(t, "bob")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	joined := waitFor(t, alice, isType(EventJoined)).Data.(*JoinResponse)
+	if _, err := bob.SendText(ctx, "before"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, alice, isMessage("before"))
+	seqBefore := alice.LastSeq()
+
+	// 断线期间其他用户发送的消息在重连后补发
+	server.dropConnections(true)
+	waitFor(t, alice, isType(EventDisconnected))
+	waitFor(t, bob, isType(EventDisconnected))
+	pending := make(chan error, 1)
+	go func() {
+		_, err := alice.SendText(ctx, "queued")
+		pending <- err
+	}()
+	server.accept()
+
+	if _, err := bob.SendText(ctx, "missed"); err != nil {
+		t.Fatal(err)
+	}
+
+	var replayed []string
+	rejoined := waitFor(t, alice, isType(EventJoined), func(event Event) {
+		if data, ok := event.Data.(*NewMessageEvent); ok {
+			replayed = append(replayed, data.Message.Content)
+		}
+	})
+	if user := rejoined.Data.(*JoinResponse).User; user.ID != joined.User.ID {
+		t.Errorf("resumed as %s, want %s", user.ID, joined.User.ID)
+	}
+
+	missed := waitFor(t, alice, isMessage("missed"), func(event Event) {
+		if data, ok := event.Data.(*NewMessageEvent); ok {
+			replayed = append(replayed, data.Message.Content)
+		}
+	})
+	if missed.Seq <= seqBefore {
+		t.Errorf("missed message seq = %d, want after %d", missed.Seq, seqBefore)
+	}
+	for _, content := range replayed {
+		if content == "before" {
+			t.Error("message before LastSeq was replayed")
+		}
+	}
+
+	// 断线时尚未发出的Call在重连后发出，不会被当作连接断开
+	if err := <-pending; err != nil {
+		t.Errorf("queued send: %v", err)
+	}
+}
+
+func TestClientDropsCancelledCalls(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.dial(t, "alice")
+	waitFor(t, alice, isType(EventJoined))
+
+	server.dropConnections(true)
+	waitFor(t, alice, isType(EventDisconnected))
+
+	// 断线期间超时的Call不会在重连后补发
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err := alice.SendText(ctx, "abandoned")
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	server.accept()
+	waitFor(t, alice, isType(EventJoined))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := alice.SendText(ctx, "after"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, alice, isMessage("after"), func(event Event) {
+		if isMessage("abandoned")(event) {
+			t.Error("cancelled call was sent after reconnecting")
+		}
+	})
+}
+
+func TestClientRejectsUnsupportedVersion(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello request
+		if err := conn.ReadJSON(&hello); err != nil || hello.Type != EventHello {
+			return
+		}
+		// 只支持旧版本协议的服务端
+		conn.WriteJSON(map[string]interface{}{
+			"type":       EventHello,
+			"request_id": hello.RequestID,
+			"data":       json.RawMessage(`{"version":1,"supported_versions":[1],"features":[],"locale":"zh-CN"}`),
+		})
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := Dial(ctx, Options{URL: "ws" + strings.TrimPrefix(server.URL, "http"), Nickname: "alice"})
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("err = %v, want ErrUnsupportedVersion", err)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"encoding/json"
+	"pixel-chat-server/internal/models"
+	"time"
+)
+
+// 与服务端共用的数据结构
+type (
+	User           = models.User
+	Message        = models.Message
+	Room           = models.Room
+	Reaction       = models.Reaction
+	ImageAsset     = models.ImageAsset
+	CodeToken      = models.CodeToken
+	MessageQuote   = models.MessageQuote
+	ThreadSummary  = models.ThreadSummary
+	UnreadSummary  = models.UnreadSummary
+	MentionSummary = models.MentionSummary
+	MessageReport  = models.MessageReport
+)
+
+// 客户端发送的请求
+type (
+	HelloRequest          = models.HelloRequest
+	JoinRequest           = models.JoinRequest
+	SendMessageRequest    = models.SendMessageRequest
+	EditMessageRequest    = models.EditMessageRequest
+	DeleteMessageRequest  = models.DeleteMessageRequest
+	ReactRequest          = models.ReactRequest
+	MarkReadRequest       = models.MarkReadRequest
+	GetThreadRequest      = models.GetThreadRequest
+	MessageHistoryRequest = models.MessageHistoryRequest
+	ReportMessageRequest  = models.ReportMessageRequest
+	UpdateProfileRequest  = models.UpdateProfileRequest
+	SetAvatarRequest      = models.SetAvatarRequest
+	UpdateRoomRequest     = models.UpdateRoomRequest
+	PinMessageRequest     = models.PinMessageRequest
+)
+
+// 服务端推送的事件
+type (
+	HelloResponse          = models.HelloResponse
+	JoinResponse           = models.JoinResponse
+	UserJoinedEvent        = models.UserJoinedEvent
+	UserLeftEvent          = models.UserLeftEvent
+	UserUpdatedEvent       = models.UserUpdatedEvent
+	UserListEvent          = models.UserListEvent
+	NewMessageEvent        = models.NewMessageEvent
+	MessageEditedEvent     = models.MessageEditedEvent
+	MessageDeletedEvent    = models.MessageDeletedEvent
+	ReactionUpdatedEvent   = models.ReactionUpdatedEvent
+	MessageHistoryResponse = models.MessageHistoryResponse
+	MentionedEvent         = models.MentionedEvent
+	MessageSeenEvent       = models.MessageSeenEvent
+	ThreadUpdatedEvent     = models.ThreadUpdatedEvent
+	QuoteUpdatedEvent      = models.QuoteUpdatedEvent
+	ThreadResponse         = models.ThreadResponse
+	TypingEvent            = models.TypingEvent
+	RoomUpdatedEvent       = models.RoomUpdatedEvent
+	ResyncRequiredEvent    = models.ResyncRequiredEvent
+	AckEvent               = models.AckEvent
+	NackEvent              = models.NackEvent
+	ErrorEvent             = models.ErrorEvent
+)
+
+// 事件类型
+const (
+	EventHello           = "hello"
+	EventJoined          = "joined"
+	EventUserJoined      = "user_joined"
+	EventUserLeft        = "user_left"
+	EventUserUpdated     = "user_updated"
+	EventUserList        = "user_list"
+	EventNewMessage      = "new_message"
+	EventMessageEdited   = "message_edited"
+	EventMessageDeleted  = "message_deleted"
+	EventReactionUpdated = "reaction_updated"
+	EventMessageHistory  = "message_history"
+	EventMentioned       = "mentioned"
+	EventMentions        = "mentions"
+	EventUnread          = "unread"
+	EventMessageSeen     = "message_seen"
+	EventThreadUpdated   = "thread_updated"
+	EventQuoteUpdated    = "quote_updated"
+	EventThread          = "thread"
+	EventTyping          = "typing"
+	EventRoomUpdated     = "room_updated"
+	EventResyncRequired  = "resync_required"
+	EventReported        = "reported"
+	EventAck             = "ack"
+	EventNack            = "nack"
+	EventError           = "error"
+	EventPong            = "pong"
+
+	// EventDisconnected 连接断开，由客户端产生，Data为*DisconnectedEvent，之后会自动重连
+	EventDisconnected = "disconnected"
+)
+
+// eventData 各事件的数据类型，未列出的事件保留原始JSON
+var eventData = map[string]func() interface{}{
+	EventHello:           func() interface{} { return new(HelloResponse) },
+	EventJoined:          func() interface{} { return new(JoinResponse) },
+	EventUserJoined:      func() interface{} { return new(UserJoinedEvent) },
+	EventUserLeft:        func() interface{} { return new(UserLeftEvent) },
+	EventUserUpdated:     func() interface{} { return new(UserUpdatedEvent) },
+	EventUserList:        func() interface{} { return new(UserListEvent) },
+	EventNewMessage:      func() interface{} { return new(NewMessageEvent) },
+	EventMessageEdited:   func() interface{} { return new(MessageEditedEvent) },
+	EventMessageDeleted:  func() interface{} { return new(MessageDeletedEvent) },
+	EventReactionUpdated: func() interface{} { return new(ReactionUpdatedEvent) },
+	EventMessageHistory:  func() interface{} { return new(MessageHistoryResponse) },
+	EventMentioned:       func() interface{} { return new(MentionedEvent) },
+	EventMentions:        func() interface{} { return new(MentionSummary) },
+	EventUnread:          func() interface{} { return new(UnreadSummary) },
+	EventMessageSeen:     func() interface{} { return new(MessageSeenEvent) },
+	EventThreadUpdated:   func() interface{} { return new(ThreadUpdatedEvent) },
+	EventQuoteUpdated:    func() interface{} { return new(QuoteUpdatedEvent) },
+	EventThread:          func() interface{} { return new(ThreadResponse) },
+	EventTyping:          func() interface{} { return new(TypingEvent) },
+	EventRoomUpdated:     func() interface{} { return new(RoomUpdatedEvent) },
+	EventResyncRequired:  func() interface{} { return new(ResyncRequiredEvent) },
+	EventReported:        func() interface{} { return new(MessageReport) },
+	EventAck:             func() interface{} { return new(AckEvent) },
+	EventNack:            func() interface{} { return new(NackEvent) },
+	EventError:           func() interface{} { return new(ErrorEvent) },
+}
+
+// Event 收到的事件
+type Event struct {
+	Type      string
+	Seq       uint64 // 房间事件的序号，直接回复和临时状态为0
+	RequestID string // 对Call请求的回复带有请求ID
+
+	// Data 事件数据，已知事件为对应结构体的指针，如 *NewMessageEvent
+	// 未知事件为 json.RawMessage，pong 为nil
+	Data interface{}
+}
+
+// DisconnectedEvent 连接断开事件
+type DisconnectedEvent struct {
+	Err     error         // 断开的原因
+	RetryIn time.Duration // 距离下一次重连的时间
+}
+
+// ServerError 服务端返回的错误（error或nack事件）
+type ServerError struct {
+	Code    string // 错误码，如 RATE_LIMITED
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// envelope 收到的消息外层结构，与 models.WebSocketMessage 对应，Data延迟解析
+type envelope struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	Seq       uint64          `json:"seq,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// decodeEvent 解析收到的帧
+func decodeEvent(frame []byte) (Event, error) {
+	var env envelope
+	if err := json.Unmarshal(frame, &env); err != nil {
+		return Event{}, err
+	}
+
+	event := Event{Type: env.Type, Seq: env.Seq, RequestID: env.RequestID}
+	if len(env.Data) == 0 || string(env.Data) == "null" {
+		return event, nil
+	}
+
+	newData, known := eventData[env.Type]
+	if !known {
+		event.Data = env.Data
+		return event, nil
+	}
+	data := newData()
+	if err := json.Unmarshal(env.Data, data); err != nil {
+		return Event{}, err
+	}
+	event.Data = data
+	return event, nil
+}
+
+// errorOf 将error和nack事件转换为错误，其他事件返回nil
+func errorOf(event Event) error {
+	switch data := event.Data.(type) {
+	case *ErrorEvent:
+		return &ServerError{Code: data.Code, Message: data.Message}
+	case *NackEvent:
+		return &ServerError{Code: data.Code, Message: data.Message}
+	}
+	return nil
+}
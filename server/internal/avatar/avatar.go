@@ -0,0 +1,204 @@
+package avatar
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"math/rand"
+	"strings"
+)
+
+const (
+	// Size 默认头像尺寸（8x8像素）
+	Size = 8
+
+	// LargeSize 高清头像尺寸（16x16像素）
+	LargeSize = 16
+
+	// Transparent 透明像素的调色板索引
+	Transparent = 0
+)
+
+// Palette 头像的8位调色板，共256色，0为透明
+// 0-15为基础色，16-231为6x6x6色立方体，232-255为灰阶
+var Palette = buildPalette()
+
+// basePalette 基础16色，只使用基础色的头像每个像素编码为一个16进制字符
+var basePalette = []color.NRGBA{
+	{0x00, 0x00, 0x00, 0x00}, // 0 透明
+	{0x0D, 0x11, 0x17, 0xFF}, // 1 深空灰
+	{0x16, 0x1B, 0x22, 0xFF}, // 2 暗灰
+	{0x58, 0xA6, 0xFF, 0xFF}, // 3 霓虹蓝
+	{0x3F, 0xB9, 0x50, 0xFF}, // 4 像素绿
+	{0xF8, 0x51, 0x49, 0xFF}, // 5 警戒红
+	{0xC9, 0xD1, 0xD9, 0xFF}, // 6 浅灰
+	{0xE6, 0xED, 0xF3, 0xFF}, // 7 纯白
+	{0xFF, 0x6B, 0x6B, 0xFF}, // 8 像素红
+	{0x4E, 0xCD, 0xC4, 0xFF}, // 9 青绿
+	{0xFF, 0xE6, 0x6D, 0xFF}, // A 像素黄
+	{0xA8, 0xDA, 0xDC, 0xFF}, // B 浅蓝
+	{0xF4, 0xA2, 0x61, 0xFF}, // C 像素橙
+	{0x9B, 0x5D, 0xE5, 0xFF}, // D 像素紫
+	{0x6E, 0x76, 0x81, 0xFF}, // E 中灰
+	{0x00, 0x00, 0x00, 0xFF}, // F 纯黑
+}
+
+// cubeLevels 色立方体每个通道的取值
+var cubeLevels = [6]uint8{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+
+// buildPalette 在基础色之后追加色立方体和灰阶
+func buildPalette() []color.NRGBA {
+	palette := make([]color.NRGBA, 0, 256)
+	palette = append(palette, basePalette...)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				palette = append(palette, color.NRGBA{cubeLevels[r], cubeLevels[g], cubeLevels[b], 0xFF})
+			}
+		}
+	}
+	for i := 0; i < 24; i++ {
+		level := uint8(0x08 + i*10)
+		palette = append(palette, color.NRGBA{level, level, level, 0xFF})
+	}
+	return palette
+}
+
+// accentColors 随机生成头像时可选的前景色
+var accentColors = []int{3, 4, 5, 8, 9, 10, 11, 12, 13}
+
+// Avatar 像素头像，Pixels按行存储调色板索引
+type Avatar struct {
+	Width  int
+	Height int
+	Pixels []uint8
+}
+
+// New 创建指定尺寸的空白头像
+func New(size int) *Avatar {
+	return &Avatar{
+		Width:  size,
+		Height: size,
+		Pixels: make([]uint8, size*size),
+	}
+}
+
+// At 获取指定位置的调色板索引
+func (a *Avatar) At(x, y int) uint8 {
+	return a.Pixels[y*a.Width+x]
+}
+
+// Set 设置指定位置的调色板索引
+func (a *Avatar) Set(x, y int, index uint8) {
+	a.Pixels[y*a.Width+x] = index
+}
+
+// String 编码头像，只使用基础色时每个像素对应一个16进制字符，否则对应两个
+func (a *Avatar) String() string {
+	const digits = "0123456789ABCDEF"
+
+	wide := false
+	for _, index := range a.Pixels {
+		if int(index) >= len(basePalette) {
+			wide = true
+			break
+		}
+	}
+
+	var builder strings.Builder
+	if !wide {
+		builder.Grow(len(a.Pixels))
+		for _, index := range a.Pixels {
+			builder.WriteByte(digits[index])
+		}
+		return builder.String()
+	}
+
+	builder.Grow(len(a.Pixels) * 2)
+	for _, index := range a.Pixels {
+		builder.WriteByte(digits[index>>4])
+		builder.WriteByte(digits[index&0x0F])
+	}
+	return builder.String()
+}
+
+// Decode 解析头像编码，支持8x8和16x16两种尺寸，每个像素为一个或两个16进制字符
+func Decode(encoded string) (*Avatar, error) {
+	var size, width int
+	switch len(encoded) {
+	case Size * Size:
+		size, width = Size, 1
+	case LargeSize * LargeSize:
+		size, width = LargeSize, 1
+	case Size * Size * 2:
+		size, width = Size, 2
+	case LargeSize * LargeSize * 2:
+		size, width = LargeSize, 2
+	default:
+		return nil, fmt.Errorf("头像尺寸无效，仅支持%dx%d或%dx%d", Size, Size, LargeSize, LargeSize)
+	}
+
+	avatar := New(size)
+	for i := range avatar.Pixels {
+		var index uint8
+		for _, c := range []byte(encoded[i*width : (i+1)*width]) {
+			value, ok := hexValue(c)
+			if !ok {
+				return nil, fmt.Errorf("头像编码包含无效字符: %q", c)
+			}
+			index = index<<4 | value
+		}
+		avatar.Pixels[i] = index
+	}
+	return avatar, nil
+}
+
+// Generate 根据种子生成左右对称的8x8头像，相同种子总是得到相同头像
+func Generate(seed string) *Avatar {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	rng := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+	primary := accentColors[rng.Intn(len(accentColors))]
+	secondary := primary
+	for secondary == primary {
+		secondary = accentColors[rng.Intn(len(accentColors))]
+	}
+
+	avatar := New(Size)
+	// 像素过少的图案辨识度低，重新生成直到足够饱满
+	for filled := 0; filled < Size*Size/4; {
+		filled = 0
+		for y := 0; y < Size; y++ {
+			for x := 0; x < Size/2; x++ {
+				var index uint8 = Transparent
+				switch roll := rng.Intn(100); {
+				case roll < 55:
+					index = uint8(primary)
+				case roll < 70:
+					index = uint8(secondary)
+				}
+				if index != Transparent {
+					filled += 2
+				}
+				avatar.Set(x, y, index)
+				avatar.Set(Size-1-x, y, index)
+			}
+		}
+	}
+
+	return avatar
+}
+
+// hexValue 解析单个16进制字符
+func hexValue(c byte) (uint8, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
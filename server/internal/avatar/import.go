@@ -0,0 +1,134 @@
+package avatar
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	// MaxPNGBytes 上传头像PNG的最大字节数
+	MaxPNGBytes = 16 * 1024
+
+	// MaxPNGDimension 上传头像PNG的最大边长
+	MaxPNGDimension = 256
+
+	// alphaThreshold 平均透明度低于该值的像素视为透明
+	alphaThreshold = 0x80
+)
+
+// FromPNG 将PNG图片缩放到指定尺寸并量化到头像的8位调色板
+func FromPNG(data []byte, size int) (*Avatar, error) {
+	if size != Size && size != LargeSize {
+		return nil, fmt.Errorf("头像尺寸无效，仅支持%dx%d或%dx%d", Size, Size, LargeSize, LargeSize)
+	}
+	if len(data) > MaxPNGBytes {
+		return nil, fmt.Errorf("头像图片过大，最大%dKB", MaxPNGBytes/1024)
+	}
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("头像图片必须是PNG格式")
+	}
+	if cfg.Width < size || cfg.Height < size || cfg.Width > MaxPNGDimension || cfg.Height > MaxPNGDimension {
+		return nil, fmt.Errorf("头像图片尺寸需在%d到%d像素之间", size, MaxPNGDimension)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("头像图片解析失败")
+	}
+
+	avatar := New(size)
+	pixelateInto(avatar, img)
+	return avatar, nil
+}
+
+// Pixelate 将任意图片按原始宽高比缩小到最长边不超过maxSide个像素，并量化到调色板
+func Pixelate(img image.Image, maxSide int) *Avatar {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(1, height*maxSide/width)
+			width = maxSide
+		} else {
+			width = max(1, width*maxSide/height)
+			height = maxSide
+		}
+	}
+
+	pixelated := &Avatar{
+		Width:  width,
+		Height: height,
+		Pixels: make([]uint8, width*height),
+	}
+	pixelateInto(pixelated, img)
+	return pixelated
+}
+
+// pixelateInto 将原图按目标尺寸分块取平均色并量化
+func pixelateInto(a *Avatar, img image.Image) {
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			a.Set(x, y, Quantize(averageBlock(img, x, y, a.Width, a.Height)))
+		}
+	}
+}
+
+// Quantize 返回与给定颜色最接近的调色板索引，半透明颜色视为透明
+func Quantize(c color.NRGBA) uint8 {
+	if c.A < alphaThreshold {
+		return Transparent
+	}
+
+	best := uint8(Transparent)
+	bestDistance := -1
+	for i := 1; i < len(Palette); i++ {
+		p := Palette[i]
+		dr := int(c.R) - int(p.R)
+		dg := int(c.G) - int(p.G)
+		db := int(c.B) - int(p.B)
+		distance := dr*dr + dg*dg + db*db
+		if bestDistance < 0 || distance < bestDistance {
+			best = uint8(i)
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// averageBlock 计算原图中对应目标像素区域的平均颜色
+func averageBlock(img image.Image, x, y, width, height int) color.NRGBA {
+	bounds := img.Bounds()
+	x0 := bounds.Min.X + x*bounds.Dx()/width
+	x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+	y0 := bounds.Min.Y + y*bounds.Dy()/height
+	y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+
+	var r, g, b, a, count uint64
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			// RGBA返回预乘alpha的16位分量
+			cr, cg, cb, ca := img.At(px, py).RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			b += uint64(cb)
+			a += uint64(ca)
+			count++
+		}
+	}
+	if count == 0 || a == 0 {
+		return color.NRGBA{}
+	}
+
+	// 按alpha还原非预乘颜色，再转换为8位分量
+	return color.NRGBA{
+		R: uint8(r * 0xFF / a),
+		G: uint8(g * 0xFF / a),
+		B: uint8(b * 0xFF / a),
+		A: uint8(a / count >> 8),
+	}
+}
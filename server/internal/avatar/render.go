@@ -0,0 +1,91 @@
+package avatar
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+const (
+	// DefaultScale 默认放大倍数
+	DefaultScale = 8
+
+	// MaxScale 最大放大倍数
+	MaxScale = 32
+)
+
+// colorPalette 转换为image/color调色板
+func colorPalette() color.Palette {
+	palette := make(color.Palette, len(Palette))
+	for i, c := range Palette {
+		palette[i] = c
+	}
+	return palette
+}
+
+// RenderPNG 将头像按倍数放大后渲染为PNG，每个像素保持锐利边缘
+func RenderPNG(a *Avatar, scale int) ([]byte, error) {
+	scale = ClampScale(scale)
+
+	img := image.NewPaletted(image.Rect(0, 0, a.Width*scale, a.Height*scale), colorPalette())
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			index := a.At(x, y)
+			for dy := 0; dy < scale; dy++ {
+				row := (y*scale + dy) * img.Stride
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[row+x*scale+dx] = index
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderSVG 将头像渲染为SVG，同一行中相邻的同色像素合并为一个矩形
+func RenderSVG(a *Avatar, scale int) []byte {
+	scale = ClampScale(scale)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		a.Width*scale, a.Height*scale, a.Width, a.Height)
+
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; {
+			index := a.At(x, y)
+			run := 1
+			for x+run < a.Width && a.At(x+run, y) == index {
+				run++
+			}
+			if index != Transparent {
+				c := Palette[index]
+				fmt.Fprintf(&builder, `<rect x="%d" y="%d" width="%d" height="1" fill="#%02X%02X%02X"/>`,
+					x, y, run, c.R, c.G, c.B)
+			}
+			x += run
+		}
+	}
+
+	builder.WriteString(`</svg>`)
+	return []byte(builder.String())
+}
+
+// ClampScale 将放大倍数限制在合法范围内，非正数使用默认倍数
+func ClampScale(scale int) int {
+	if scale <= 0 {
+		return DefaultScale
+	}
+	if scale > MaxScale {
+		return MaxScale
+	}
+	return scale
+}
@@ -0,0 +1,150 @@
+// Package bots 在服务端进程内运行机器人插件，插件收到聊天室的新消息后可以用机器人身份回复
+package bots
+
+import (
+	"fmt"
+	"log"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+	"strings"
+)
+
+// messageBuffer 等待插件处理的消息数，插件处理不过来时丢弃新消息
+const messageBuffer = 256
+
+// Bot 进程内机器人插件
+type Bot interface {
+	// Name 机器人的昵称，注册时据此创建机器人账号
+	Name() string
+	// HandleMessage 处理聊天室的新消息，需要回复时调用ctx.Reply
+	// 所有插件在同一个goroutine中依次调用，耗时的操作应自行放到其他goroutine
+	HandleMessage(ctx *Context, message *models.Message)
+}
+
+// Context 插件处理消息时的上下文
+type Context struct {
+	registry *Registry
+	bot      *models.Bot
+	message  *models.Message
+}
+
+// Bot 返回插件对应的机器人账号
+func (c *Context) Bot() *models.Bot {
+	return c.bot
+}
+
+// Reply 以机器人身份引用并回复正在处理的消息，消息属于话题时归入同一话题
+func (c *Context) Reply(content string) error {
+	return c.Send(&models.SendMessageRequest{
+		Content: content,
+		ReplyTo: c.message.ID,
+	})
+}
+
+// Send 以机器人身份发送消息
+func (c *Context) Send(req *models.SendMessageRequest) error {
+	message, duplicate, err := c.registry.chatService.PostBotMessage(c.bot, req)
+	if err != nil {
+		return err
+	}
+	if !duplicate {
+		c.registry.publish(message)
+	}
+	return nil
+}
+
+// plugin 已注册的插件及其机器人账号
+type plugin struct {
+	bot     Bot
+	account *models.Bot
+}
+
+// Registry 管理进程内机器人插件，并把新消息分发给插件
+type Registry struct {
+	chatService *services.ChatService
+	plugins     []*plugin
+	messages    chan *models.Message
+	publish     func(message *models.Message)
+}
+
+func NewRegistry(chatService *services.ChatService) *Registry {
+	return &Registry{
+		chatService: chatService,
+		messages:    make(chan *models.Message, messageBuffer),
+	}
+}
+
+// Register 注册插件并创建对应的内置机器人账号，需要在Run之前调用
+func (r *Registry) Register(bot Bot) error {
+	account, err := r.chatService.RegisterBuiltinBot(bot.Name())
+	if err != nil {
+		return err
+	}
+	r.plugins = append(r.plugins, &plugin{bot: bot, account: account})
+	return nil
+}
+
+// Notify 通知插件有新消息，不会阻塞；未注册任何插件时忽略
+func (r *Registry) Notify(message *models.Message) {
+	if r == nil || len(r.plugins) == 0 {
+		return
+	}
+
+	select {
+	case r.messages <- message:
+	default:
+		log.Printf("机器人消息队列已满，丢弃消息 %s", message.ID)
+	}
+}
+
+// Run 依次把新消息交给各插件处理，publish用于广播机器人发送的消息，需要在单独的goroutine中运行
+func (r *Registry) Run(publish func(message *models.Message)) {
+	r.publish = publish
+
+	for message := range r.messages {
+		// 机器人之间不互相响应，避免循环
+		if message.UserIsBot || message.Type == "system" || message.Deleted {
+			continue
+		}
+		for _, p := range r.plugins {
+			r.handle(p, message)
+		}
+	}
+}
+
+// handle 调用单个插件，插件出错不影响其他插件和服务
+func (r *Registry) handle(p *plugin, message *models.Message) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("机器人 %s 处理消息时出错: %v", p.account.Name, recovered)
+		}
+	}()
+
+	p.bot.HandleMessage(&Context{registry: r, bot: p.account, message: message}, message)
+}
+
+// ParseCommand 解析 /name args 形式的命令，命令名不区分大小写，不是命令时返回false
+func ParseCommand(content string) (name, args string, ok bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "/") {
+		return "", "", false
+	}
+
+	name, args, _ = strings.Cut(content[1:], " ")
+	if name == "" {
+		return "", "", false
+	}
+	return strings.ToLower(name), strings.TrimSpace(args), true
+}
+
+// Builtin 按名称创建内置机器人，支持 roll 和 uptime
+func Builtin(name string) (Bot, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "roll":
+		return NewRollBot(), nil
+	case "uptime":
+		return NewUptimeBot(), nil
+	default:
+		return nil, fmt.Errorf("未知的内置机器人: %s", name)
+	}
+}
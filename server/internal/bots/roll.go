@@ -0,0 +1,118 @@
+package bots
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"pixel-chat-server/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxDice 一次最多掷的骰子数
+	maxDice = 20
+
+	// maxSides 骰子的最大面数
+	maxSides = 1000
+
+	// maxModifier 加减值的最大绝对值
+	maxModifier = 1000
+)
+
+// dicePattern 骰子表达式，如 d20、2d6、3d8+2，只有数字时表示面数
+var dicePattern = regexp.MustCompile(`^(?:(\d*)d)?(\d+)([+-]\d+)?$`)
+
+// RollBot 响应 /roll 命令掷骰子
+type RollBot struct {
+	intn func(n int) int
+}
+
+func NewRollBot() *RollBot {
+	return &RollBot{intn: rand.Intn}
+}
+
+// Name 机器人的昵称
+func (b *RollBot) Name() string {
+	return "骰子"
+}
+
+// HandleMessage 处理 /roll [表达式]，默认掷一个六面骰
+func (b *RollBot) HandleMessage(ctx *Context, message *models.Message) {
+	name, args, ok := ParseCommand(message.Content)
+	if !ok || name != "roll" {
+		return
+	}
+
+	reply, err := b.roll(args)
+	if err != nil {
+		reply = err.Error()
+	} else {
+		reply = fmt.Sprintf("🎲 %s 掷出 %s", message.UserNickname, reply)
+	}
+	if err := ctx.Reply(reply); err != nil {
+		log.Printf("骰子机器人回复失败: %v", err)
+	}
+}
+
+// roll 按表达式掷骰子，返回表达式和结果说明
+func (b *RollBot) roll(expr string) (string, error) {
+	expr = strings.ToLower(strings.ReplaceAll(expr, " ", ""))
+	if expr == "" {
+		expr = "d6"
+	}
+
+	groups := dicePattern.FindStringSubmatch(expr)
+	if groups == nil {
+		return "", fmt.Errorf("用法: /roll [数量]d<面数>[+加值]，例如 /roll 2d6+1")
+	}
+
+	count := 1
+	if groups[1] != "" {
+		count, _ = strconv.Atoi(groups[1])
+	}
+	sides, _ := strconv.Atoi(groups[2])
+	modifier := 0
+	if groups[3] != "" {
+		modifier, _ = strconv.Atoi(groups[3])
+	}
+	if count < 1 || count > maxDice || sides < 2 || sides > maxSides || modifier < -maxModifier || modifier > maxModifier {
+		return "", fmt.Errorf("骰子数量为1-%d个，面数为2-%d", maxDice, maxSides)
+	}
+
+	rolls := make([]string, count)
+	total := modifier
+	for i := range rolls {
+		value := b.intn(sides) + 1
+		rolls[i] = strconv.Itoa(value)
+		total += value
+	}
+
+	notation := fmt.Sprintf("%dd%d", count, sides)
+	detail := strings.Join(rolls, " + ")
+	if modifier != 0 {
+		notation += fmt.Sprintf("%+d", modifier)
+		detail += fmt.Sprintf(" %s %d", sign(modifier), abs(modifier))
+	}
+	if count == 1 && modifier == 0 {
+		return fmt.Sprintf("%s: %d", notation, total), nil
+	}
+	return fmt.Sprintf("%s: %s = %d", notation, detail, total), nil
+}
+
+// sign 返回加减号
+func sign(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+// abs 返回绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
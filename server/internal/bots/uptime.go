@@ -0,0 +1,56 @@
+package bots
+
+import (
+	"fmt"
+	"log"
+	"pixel-chat-server/internal/models"
+	"time"
+)
+
+// UptimeBot 响应 /uptime 命令报告服务运行时长
+type UptimeBot struct {
+	now func() time.Time
+}
+
+func NewUptimeBot() *UptimeBot {
+	return &UptimeBot{now: time.Now}
+}
+
+// Name 机器人的昵称
+func (b *UptimeBot) Name() string {
+	return "运行时间"
+}
+
+// HandleMessage 处理 /uptime
+func (b *UptimeBot) HandleMessage(ctx *Context, message *models.Message) {
+	if name, _, ok := ParseCommand(message.Content); !ok || name != "uptime" {
+		return
+	}
+
+	// 与 /api/stats 一样从聊天服务的启动时间算起
+	started := ctx.registry.chatService.StartTime()
+	reply := fmt.Sprintf("⏱ 服务器已运行 %s（启动于 %s）",
+		formatUptime(b.now().Sub(started)), started.Format("2006-01-02 15:04:05"))
+	if err := ctx.Reply(reply); err != nil {
+		log.Printf("运行时间机器人回复失败: %v", err)
+	}
+}
+
+// formatUptime 将时长格式化为天、小时、分钟、秒，省略开头为0的单位
+func formatUptime(d time.Duration) string {
+	seconds := int(d / time.Second)
+	days, seconds := seconds/86400, seconds%86400
+	hours, seconds := seconds/3600, seconds%3600
+	minutes, seconds := seconds/60, seconds%60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d天%d小时%d分%d秒", days, hours, minutes, seconds)
+	case hours > 0:
+		return fmt.Sprintf("%d小时%d分%d秒", hours, minutes, seconds)
+	case minutes > 0:
+		return fmt.Sprintf("%d分%d秒", minutes, seconds)
+	default:
+		return fmt.Sprintf("%d秒", seconds)
+	}
+}
@@ -6,28 +6,50 @@ import (
 )
 
 type Config struct {
-	Port                    string
-	GinMode                 string
-	CORSOrigin              string
-	RateLimitWindowSeconds  int
-	RateLimitMaxRequests    int
-	MaxMessageLength        int
-	MaxMessagesHistory      int
-	MaxUsersPerRoom         int
-	UserTimeoutSeconds      int
+	Port                   string
+	GinMode                string
+	CORSOrigin             string
+	RateLimitWindowSeconds int
+	RateLimitMaxRequests   int
+	MaxMessageLength       int
+	MaxCodeLength          int
+	MaxMessagesHistory     int
+	MaxUsersPerRoom        int
+	UserTimeoutSeconds     int
+	TripcodePepper         string
+	AdminToken             string
+	EditWindowSeconds      int
+	SendRateLimit          int
+	UploadDir              string
+	WSCompression          bool
+	WSCompressionLevel     int
+	WSCompressionThreshold int
+	WebhookStore           string
+	BuiltinBots            string
 }
 
 func Load() *Config {
 	return &Config{
-		Port:                    getEnv("PORT", "3001"),
-		GinMode:                 getEnv("GIN_MODE", "debug"),
-		CORSOrigin:              getEnv("CORS_ORIGIN", "http://localhost:3000"),
-		RateLimitWindowSeconds:  getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 900),
-		RateLimitMaxRequests:    getEnvAsInt("RATE_LIMIT_MAX_REQUESTS", 100),
-		MaxMessageLength:        getEnvAsInt("MAX_MESSAGE_LENGTH", 500),
-		MaxMessagesHistory:      getEnvAsInt("MAX_MESSAGES_HISTORY", 1000),
-		MaxUsersPerRoom:         getEnvAsInt("MAX_USERS_PER_ROOM", 100),
-		UserTimeoutSeconds:      getEnvAsInt("USER_TIMEOUT_SECONDS", 300),
+		Port:                   getEnv("PORT", "3001"),
+		GinMode:                getEnv("GIN_MODE", "debug"),
+		CORSOrigin:             getEnv("CORS_ORIGIN", "http://localhost:3000"),
+		RateLimitWindowSeconds: getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 900),
+		RateLimitMaxRequests:   getEnvAsInt("RATE_LIMIT_MAX_REQUESTS", 100),
+		MaxMessageLength:       getEnvAsInt("MAX_MESSAGE_LENGTH", 500),
+		MaxCodeLength:          getEnvAsInt("MAX_CODE_LENGTH", 4000),
+		MaxMessagesHistory:     getEnvAsInt("MAX_MESSAGES_HISTORY", 1000),
+		MaxUsersPerRoom:        getEnvAsInt("MAX_USERS_PER_ROOM", 100),
+		UserTimeoutSeconds:     getEnvAsInt("USER_TIMEOUT_SECONDS", 300),
+		TripcodePepper:         getEnv("TRIPCODE_PEPPER", ""),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		EditWindowSeconds:      getEnvAsInt("MESSAGE_EDIT_WINDOW_SECONDS", 300),
+		SendRateLimit:          getEnvAsInt("SEND_RATE_LIMIT", 60),
+		UploadDir:              getEnv("UPLOAD_DIR", "uploads"),
+		WSCompression:          getEnvAsBool("WS_COMPRESSION", true),
+		WSCompressionLevel:     getEnvAsInt("WS_COMPRESSION_LEVEL", 1),
+		WSCompressionThreshold: getEnvAsInt("WS_COMPRESSION_THRESHOLD", 1024),
+		WebhookStore:           getEnv("WEBHOOK_STORE", "data/outgoing_webhooks.json"),
+		BuiltinBots:            getEnv("BUILTIN_BOTS", "roll,uptime"),
 	}
 }
 
@@ -46,3 +68,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiToken 根据 Authorization: Bearer <api_token> 获取API令牌，失败时直接写入401响应
+func (h *Handlers) apiToken(c *gin.Context) (*models.APIToken, bool) {
+	// 必须使用Bearer方案，避免其他认证方式的凭据被当作令牌校验
+	if secret, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		if token, exists := h.chatService.AuthenticateAPIToken(secret); exists {
+			return token, true
+		}
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "API令牌无效"})
+	return nil, false
+}
+
+// PostMessage 使用API令牌向默认聊天室发送消息
+func (h *Handlers) PostMessage(c *gin.Context) {
+	h.postMessage(c, services.DefaultRoomID)
+}
+
+// PostRoomMessage 使用API令牌向指定聊天室发送消息
+func (h *Handlers) PostRoomMessage(c *gin.Context) {
+	h.postMessage(c, c.Param("room"))
+}
+
+// postMessage 以API令牌的身份发送消息并广播，重复的client_id返回首次发送的消息
+func (h *Handlers) postMessage(c *gin.Context, roomID string) {
+	token, ok := h.apiToken(c)
+	if !ok {
+		return
+	}
+
+	var sendReq models.SendMessageRequest
+	if err := c.ShouldBindJSON(&sendReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的消息请求"})
+		return
+	}
+
+	message, duplicate, err := h.chatService.PostMessageWithToken(token, roomID, &sendReq)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"message": message, "duplicate": true})
+		return
+	}
+	h.hub.BroadcastNewMessage(message)
+	c.JSON(http.StatusCreated, gin.H{"message": message})
+}
+
+// CreateAPIToken 创建API令牌（仅管理员），令牌只在响应中返回一次
+func (h *Handlers) CreateAPIToken(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var tokenReq models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&tokenReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的令牌请求"})
+		return
+	}
+
+	token, secret, err := h.chatService.CreateAPIToken(&tokenReq, actorID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPITokenResponse{Token: token, Secret: secret})
+}
+
+// ListAPITokens 列出API令牌（仅管理员），不包含令牌本身
+func (h *Handlers) ListAPITokens(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": h.chatService.ListAPITokens()})
+}
+
+// RevokeAPIToken 吊销API令牌（仅管理员）
+func (h *Handlers) RevokeAPIToken(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := h.chatService.RevokeAPIToken(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// errorStatus 将业务错误码映射为HTTP状态码
+func errorStatus(err error) int {
+	switch services.ErrorCode(err) {
+	case services.CodeForbidden:
+		return http.StatusForbidden
+	case services.CodeRateLimited:
+		return http.StatusTooManyRequests
+	case services.CodeRoomNotFound, services.CodeMessageNotFound, services.CodeImageNotFound, services.CodeTokenNotFound, services.CodeWebhookNotFound, services.CodeBotNotFound:
+		return http.StatusNotFound
+	case services.CodeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
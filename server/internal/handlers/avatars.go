@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 用户头像可能变化，只短暂缓存
+	userAvatarCacheControl = "public, max-age=300"
+
+	// 按编码直接渲染的头像内容不会变化
+	encodedAvatarCacheControl = "public, max-age=31536000, immutable"
+)
+
+// GetAvatar 渲染头像，路径形如 /api/avatars/:id.png 或 /api/avatars/:id.svg
+// id 可以是用户ID（User#XXXX 需URL编码，或直接使用4位16进制部分），也可以是头像编码本身
+func (h *Handlers) GetAvatar(c *gin.Context) {
+	file := c.Param("file")
+	ext := ""
+	switch {
+	case strings.HasSuffix(file, ".png"):
+		ext = "png"
+	case strings.HasSuffix(file, ".svg"):
+		ext = "svg"
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "仅支持png和svg格式"})
+		return
+	}
+	id := strings.TrimSuffix(file, "."+ext)
+
+	encoded, cacheControl, ok := h.resolveAvatar(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "头像不存在"})
+		return
+	}
+
+	img, err := avatar.Decode(encoded)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "头像不存在"})
+		return
+	}
+
+	scale, err := strconv.Atoi(c.DefaultQuery("scale", strconv.Itoa(avatar.DefaultScale)))
+	if err != nil {
+		scale = avatar.DefaultScale
+	}
+	// 按实际渲染的倍数计算ETag，超出范围的请求与限制后的请求共用缓存
+	scale = avatar.ClampScale(scale)
+
+	sum := sha1.Sum([]byte(encoded + "/" + ext + "/" + strconv.Itoa(scale)))
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	switch ext {
+	case "png":
+		data, err := avatar.RenderPNG(img, scale)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "头像渲染失败"})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", data)
+	case "svg":
+		c.Data(http.StatusOK, "image/svg+xml", avatar.RenderSVG(img, scale))
+	}
+}
+
+// SetMyAvatar 设置当前用户的头像
+// 请求体可以是JSON（models.SetAvatarRequest），也可以是 Content-Type: image/png 的原始图片，此时通过 size 参数指定目标尺寸
+func (h *Handlers) SetMyAvatar(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	var avatarReq models.SetAvatarRequest
+	if c.ContentType() == "image/png" {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, avatar.MaxPNGBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取头像图片失败"})
+			return
+		}
+		avatarReq.PNG = data
+		avatarReq.Size, _ = strconv.Atoi(c.Query("size"))
+	} else if err := c.ShouldBindJSON(&avatarReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的头像请求"})
+		return
+	}
+
+	updated, err := h.chatService.SetAvatar(user.SocketID, &avatarReq)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	h.hub.BroadcastUserUpdated(updated)
+	c.JSON(http.StatusOK, gin.H{"user": updated})
+}
+
+// resolveAvatar 解析头像标识，返回头像编码和对应的缓存策略
+// 按用户资料查找，已离开的用户在资料保留期内仍可获取头像，供历史消息显示
+func (h *Handlers) resolveAvatar(id string) (string, string, bool) {
+	if user, exists := h.chatService.GetProfile(normalizeUserID(id)); exists {
+		return user.Avatar, userAvatarCacheControl, true
+	}
+
+	if _, err := avatar.Decode(id); err == nil {
+		return id, encodedAvatarCacheControl, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBot 创建机器人（仅管理员），令牌只在响应中返回一次，机器人在join时携带bot_token连接
+func (h *Handlers) CreateBot(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var botReq models.CreateBotRequest
+	if err := c.ShouldBindJSON(&botReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的机器人请求"})
+		return
+	}
+
+	bot, token, err := h.chatService.CreateBot(&botReq, actorID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateBotResponse{Bot: bot, Token: token})
+}
+
+// ListBots 列出机器人（仅管理员），包括内置机器人，不包含令牌
+func (h *Handlers) ListBots(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bots": h.chatService.ListBots()})
+}
+
+// DeleteBot 删除机器人并吊销令牌（仅管理员）
+func (h *Handlers) DeleteBot(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := h.chatService.DeleteBot(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
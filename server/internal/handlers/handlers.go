@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"pixel-chat-server/internal/models"
 	"pixel-chat-server/internal/services"
 	ws "pixel-chat-server/internal/websocket"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,16 +15,50 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// maxProfileIDs 批量查询用户资料时最多的用户ID数
+const maxProfileIDs = 100
+
 type Handlers struct {
 	chatService *services.ChatService
 	hub         *ws.Hub
+	streamSlots chan struct{} // SSE订阅的名额，限制同时保持的订阅数
 }
 
 func NewHandlers(chatService *services.ChatService, hub *ws.Hub) *Handlers {
 	return &Handlers{
 		chatService: chatService,
 		hub:         hub,
+		streamSlots: make(chan struct{}, maxStreamSubscribers),
+	}
+}
+
+// sessionUser 根据 Authorization: Bearer <session_token> 获取当前用户，失败时直接写入401响应
+func (h *Handlers) sessionUser(c *gin.Context) (*models.User, bool) {
+	// 与API令牌一样必须使用Bearer方案
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found && token != "" {
+		if user, exists := h.chatService.GetUserBySessionToken(token); exists {
+			return user, true
+		}
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "会话无效，请重新加入聊天室"})
+	return nil, false
+}
+
+// requireAdmin 校验 Authorization: Bearer 携带的是管理员令牌或管理员的会话令牌，失败时直接写入403响应
+// 返回操作者标识，使用管理员令牌时为 admin，使用会话令牌时为该用户的ID
+func (h *Handlers) requireAdmin(c *gin.Context) (string, bool) {
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		if h.chatService.IsAdminToken(token) {
+			return "admin", true
+		}
+		if user, exists := h.chatService.GetUserBySessionToken(token); exists && user.IsAdmin {
+			return user.ID, true
+		}
 	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+	return "", false
 }
 
 // HealthCheck 健康检查
@@ -48,6 +85,37 @@ func (h *Handlers) GetUsers(c *gin.Context) {
 	})
 }
 
+// GetUserProfile 获取用户资料，最近离开的用户同样可以查询
+func (h *Handlers) GetUserProfile(c *gin.Context) {
+	user, exists := h.chatService.GetProfile(normalizeUserID(c.Param("id")))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// GetProfiles 批量获取用户资料，用于按用户ID解析历史消息的昵称和头像
+func (h *Handlers) GetProfiles(c *gin.Context) {
+	ids := strings.Split(c.Query("ids"), ",")
+	if len(ids) > maxProfileIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多查询%d个用户", maxProfileIDs)})
+		return
+	}
+
+	profiles := make(map[string]*models.User)
+	for _, id := range ids {
+		userID := normalizeUserID(strings.TrimSpace(id))
+		if user, exists := h.chatService.GetProfile(userID); exists {
+			profiles[userID] = user
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users": profiles,
+		"count": len(profiles),
+	})
+}
+
 // GetMessages 获取消息列表
 func (h *Handlers) GetMessages(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "50")
@@ -63,12 +131,56 @@ func (h *Handlers) GetMessages(c *gin.Context) {
 	})
 }
 
+// GetMyUnread 获取当前用户的已读位置和未读消息数
+func (h *Handlers) GetMyUnread(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": h.chatService.GetUnread(user)})
+}
+
+// GetThread 获取话题
+func (h *Handlers) GetThread(c *gin.Context) {
+	thread, err := h.chatService.GetThread(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+// GetMessageHistory 获取消息编辑历史，仅管理员可用
+func (h *Handlers) GetMessageHistory(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	history, err := h.chatService.GetMessageHistory(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetEmoji 获取表情目录
+func (h *Handlers) GetEmoji(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"emoji": services.EmojiCatalog,
+		"count": len(services.EmojiCatalog),
+	})
+}
+
 // HandleWebSocket 处理WebSocket连接
 func (h *Handlers) HandleWebSocket(c *gin.Context) {
-	// 升级HTTP连接为WebSocket
+	// 升级HTTP连接为WebSocket，按客户端提供的子协议协商编码，按配置协商压缩
 	upgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		Subprotocols:      ws.Subprotocols(),
+		EnableCompression: h.hub.CompressionEnabled(),
 		CheckOrigin: func(r *http.Request) bool {
 			return true // 在生产环境中应该检查origin
 		},
@@ -86,3 +198,11 @@ func (h *Handlers) HandleWebSocket(c *gin.Context) {
 	// 处理WebSocket连接
 	h.hub.HandleWebSocket(conn, socketID)
 }
+
+// normalizeUserID 将4位16进制简写转换为完整的用户ID
+func normalizeUserID(id string) string {
+	if len(id) == 4 {
+		return "User#" + strings.ToUpper(id)
+	}
+	return id
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateOutgoingWebhook 注册传出webhook（仅管理员），签名密钥只在响应中返回一次
+func (h *Handlers) CreateOutgoingWebhook(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var webhookReq models.CreateOutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的webhook请求"})
+		return
+	}
+
+	webhook, secret, err := h.chatService.CreateOutgoingWebhook(&webhookReq, actorID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateOutgoingWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// ListOutgoingWebhooks 列出传出webhook（仅管理员），不包含签名密钥
+func (h *Handlers) ListOutgoingWebhooks(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": h.chatService.ListOutgoingWebhooks()})
+}
+
+// UpdateOutgoingWebhook 修改传出webhook的地址、订阅事件或启用状态（仅管理员）
+func (h *Handlers) UpdateOutgoingWebhook(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	var webhookReq models.UpdateOutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的webhook请求"})
+		return
+	}
+
+	webhook, err := h.chatService.UpdateOutgoingWebhook(c.Param("id"), &webhookReq)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+// DeleteOutgoingWebhook 删除传出webhook及其待投递的事件（仅管理员）
+func (h *Handlers) DeleteOutgoingWebhook(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := h.chatService.DeleteOutgoingWebhook(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries 获取传出webhook最近的投递记录（仅管理员），最新的在前
+func (h *Handlers) GetWebhookDeliveries(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	deliveries, err := h.chatService.GetWebhookDeliveries(c.Param("id"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// PingOutgoingWebhook 向传出webhook发送ping测试事件（仅管理员），结果见投递记录
+func (h *Handlers) PingOutgoingWebhook(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := h.chatService.PingOutgoingWebhook(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"ok": true})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRoom 获取聊天室的话题、公告和置顶消息
+func (h *Handlers) GetRoom(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"room": h.chatService.GetRoom()})
+}
+
+// UpdateRoom 修改聊天室话题和公告（仅管理员）
+func (h *Handlers) UpdateRoom(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var roomReq models.UpdateRoomRequest
+	if err := c.ShouldBindJSON(&roomReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的聊天室请求"})
+		return
+	}
+
+	room, err := h.chatService.UpdateRoom(actorID, &roomReq)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	h.hub.BroadcastRoomUpdated(room)
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// PinMessage 置顶消息（仅管理员）
+func (h *Handlers) PinMessage(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var pinReq models.PinMessageRequest
+	if err := c.ShouldBindJSON(&pinReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的置顶请求"})
+		return
+	}
+
+	room, err := h.chatService.PinMessage(actorID, &pinReq)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	h.hub.BroadcastRoomUpdated(room)
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// UnpinMessage 取消置顶（仅管理员）
+func (h *Handlers) UnpinMessage(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	room, err := h.chatService.UnpinMessage(actorID, &models.PinMessageRequest{MessageID: c.Param("id")})
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	h.hub.BroadcastRoomUpdated(room)
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamKeepAlive SSE心跳间隔，避免代理关闭空闲连接
+	streamKeepAlive = 25 * time.Second
+
+	// streamRetry 建议客户端断线后的重连间隔
+	streamRetry = 3 * time.Second
+
+	// maxStreamSubscribers 同时保持的SSE订阅数上限，超出时返回503
+	maxStreamSubscribers = 200
+)
+
+// Stream 以Server-Sent Events推送房间事件，事件ID为房间事件序号
+// 重连时通过Last-Event-ID（序号或消息ID）补发错过的事件
+func (h *Handlers) Stream(c *gin.Context) {
+	if room := c.DefaultQuery("room", services.DefaultRoomID); room != services.DefaultRoomID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	lastSeq, resumable := h.resumeSeq(lastEventID)
+
+	select {
+	case h.streamSlots <- struct{}{}:
+		defer func() { <-h.streamSlots }()
+	default:
+		c.Header("Retry-After", strconv.Itoa(int(streamRetry.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "订阅数已达上限，请稍后再试"})
+		return
+	}
+
+	stream := h.hub.Subscribe(lastSeq)
+	defer h.hub.Unsubscribe(stream)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry.Milliseconds())
+	if !resumable {
+		// 无法定位上次收到的事件，客户端应重新获取完整状态
+		frame, _ := json.Marshal(models.WebSocketMessage{
+			Type: "resync_required",
+			Data: models.ResyncRequiredEvent{CurrentSeq: lastSeq},
+		})
+		writeStreamEvent(c.Writer, 0, "resync_required", frame)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-stream.Events():
+			if !ok {
+				return false
+			}
+			writeStreamEvent(w, event.Seq, event.Type, event.Data)
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// resumeSeq 将Last-Event-ID转换为房间事件序号，支持序号和消息ID
+// 为空时从最新事件开始，无法识别时同样从最新事件开始并返回false
+func (h *Handlers) resumeSeq(lastEventID string) (uint64, bool) {
+	if lastEventID == "" {
+		return h.hub.Seq(), true
+	}
+	if seq, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		return seq, true
+	}
+	if message, exists := h.chatService.GetMessage(lastEventID); exists && message.Seq > 0 {
+		return message.Seq, true
+	}
+	return h.hub.Seq(), false
+}
+
+// writeStreamEvent 写入一个SSE事件，序号为0的临时事件不带ID，不影响客户端的Last-Event-ID
+func writeStreamEvent(w io.Writer, seq uint64, eventType string, data []byte) {
+	if seq > 0 {
+		fmt.Fprintf(w, "id: %d\n", seq)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+}
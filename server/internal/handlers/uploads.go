@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"pixel-chat-server/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 上传的图片按内容哈希寻址，内容不会变化
+const uploadCacheControl = "public, max-age=31536000, immutable"
+
+// UploadImage 上传图片，图片会被像素化并量化到调色板后保存
+// 请求体可以是 multipart/form-data 的 file 字段，也可以是 Content-Type: image/* 的原始图片
+func (h *Handlers) UploadImage(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxUploadBytes+64*1024)
+
+	var reader io.Reader
+	switch contentType := c.ContentType(); {
+	case contentType == "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请通过file字段上传图片"})
+			return
+		}
+		if file.Size > services.MaxUploadBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "图片过大，最大2MB"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取图片失败"})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	case strings.HasPrefix(contentType, "image/"):
+		reader = c.Request.Body
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "仅支持PNG、JPEG和GIF格式的图片"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, services.MaxUploadBytes+1))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "图片过大，最大2MB"})
+		return
+	}
+	if len(data) > services.MaxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "图片过大，最大2MB"})
+		return
+	}
+
+	asset, err := h.chatService.UploadImage(user.SocketID, data)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"upload": asset})
+}
+
+// GetUpload 获取已上传的图片，路径形如 /api/uploads/:id.png
+func (h *Handlers) GetUpload(c *gin.Context) {
+	id := strings.TrimSuffix(c.Param("file"), ".png")
+
+	path, exists := h.chatService.GetUploadPath(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	etag := `"` + id + `"`
+	c.Header("Cache-Control", uploadCacheControl)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.File(path)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBodyBytes 传入webhook请求体的最大字节数
+const maxWebhookBodyBytes = 64 * 1024
+
+// PostWebhookMessage 通过传入webhook发送消息
+// 请求体为JSON，兼容Slack的 {"text": ...} 格式和表单提交的 payload 字段
+func (h *Handlers) PostWebhookMessage(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大"})
+		return
+	}
+
+	// 很多脚本用curl -d发送JSON，Content-Type为表单，因此按内容判断格式
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("{")) {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的webhook请求"})
+			return
+		}
+		body = []byte(form.Get("payload"))
+	}
+
+	var payload models.IncomingWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的webhook请求"})
+		return
+	}
+
+	message, duplicate, err := h.chatService.PostWebhookMessage(c.Param("id"), c.Param("secret"), &payload)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	if !duplicate {
+		h.hub.BroadcastNewMessage(message)
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "message": message, "duplicate": duplicate})
+}
+
+// CreateIncomingWebhook 创建传入webhook（仅管理员），返回的URL包含密钥，只返回这一次
+func (h *Handlers) CreateIncomingWebhook(c *gin.Context) {
+	actorID, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var webhookReq models.CreateIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的webhook请求"})
+		return
+	}
+
+	webhook, secret, err := h.chatService.CreateIncomingWebhook(&webhookReq, actorID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateIncomingWebhookResponse{
+		Webhook: webhook,
+		URL:     requestBaseURL(c) + "/api/hooks/" + webhook.ID + "/" + secret,
+	})
+}
+
+// ListIncomingWebhooks 列出传入webhook（仅管理员），不包含密钥
+func (h *Handlers) ListIncomingWebhooks(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": h.chatService.ListIncomingWebhooks()})
+}
+
+// DeleteIncomingWebhook 删除传入webhook（仅管理员）
+func (h *Handlers) DeleteIncomingWebhook(c *gin.Context) {
+	if _, ok := h.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := h.chatService.DeleteIncomingWebhook(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "code": services.ErrorCode(err)})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// requestBaseURL 根据请求推断服务的外部地址，经过反向代理时使用X-Forwarded-Proto
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
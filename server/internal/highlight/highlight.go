@@ -0,0 +1,150 @@
+// Package highlight 为代码块生成简单的词法标记，客户端据此着色，无需自带完整的高亮库
+package highlight
+
+import (
+	"pixel-chat-server/internal/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 标记类型
+const (
+	TokenPlain   = "plain"
+	TokenKeyword = "keyword"
+	TokenString  = "string"
+	TokenNumber  = "number"
+	TokenComment = "comment"
+)
+
+// NormalizeLanguage 将语言名称或别名规范化，不支持的语言返回空字符串
+func NormalizeLanguage(name string) string {
+	return aliases[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// Tokenize 将代码切分为标记，标记文本按顺序拼接后与原文完全一致
+// 不支持的语言返回nil，客户端按纯文本显示
+func Tokenize(lang string, code string) []models.CodeToken {
+	spec := languages[NormalizeLanguage(lang)]
+	if spec == nil {
+		return nil
+	}
+
+	t := &tokenizer{spec: spec, src: code}
+	for t.pos < len(t.src) {
+		t.next()
+	}
+	return t.tokens
+}
+
+// tokenizer 单次切分的状态
+type tokenizer struct {
+	spec   *language
+	src    string
+	pos    int
+	tokens []models.CodeToken
+}
+
+// next 识别当前位置的一个标记
+func (t *tokenizer) next() {
+	rest := t.src[t.pos:]
+
+	for _, marker := range t.spec.lineComments {
+		if strings.HasPrefix(rest, marker) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			t.emit(TokenComment, end)
+			return
+		}
+	}
+
+	if open, close := t.spec.blockComment[0], t.spec.blockComment[1]; open != "" && strings.HasPrefix(rest, open) {
+		end := strings.Index(rest[len(open):], close)
+		if end < 0 {
+			t.emit(TokenComment, len(rest))
+		} else {
+			t.emit(TokenComment, len(open)+end+len(close))
+		}
+		return
+	}
+
+	if t.spec.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)) {
+		end := strings.Index(rest[3:], rest[:3])
+		if end < 0 {
+			t.emit(TokenString, len(rest))
+		} else {
+			t.emit(TokenString, 3+end+3)
+		}
+		return
+	}
+
+	r, size := utf8.DecodeRuneInString(rest)
+	switch {
+	case strings.ContainsRune(t.spec.quotes, r):
+		t.emit(TokenString, scanString(rest, byte(r)))
+	case isDigit(r) || (r == '.' && len(rest) > 1 && isDigit(rune(rest[1]))):
+		t.emit(TokenNumber, scanWhile(rest, func(r rune) bool {
+			return r == '.' || r == '_' || isDigit(r) || unicode.IsLetter(r)
+		}))
+	case r == '_' || unicode.IsLetter(r):
+		end := scanWhile(rest, func(r rune) bool {
+			return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+		})
+		if t.spec.keywords[rest[:end]] {
+			t.emit(TokenKeyword, end)
+		} else {
+			t.emit(TokenPlain, end)
+		}
+	default:
+		t.emit(TokenPlain, size)
+	}
+}
+
+// emit 记录长度为n的标记，相邻的同类标记合并以减小体积
+func (t *tokenizer) emit(kind string, n int) {
+	text := t.src[t.pos : t.pos+n]
+	t.pos += n
+
+	if last := len(t.tokens) - 1; last >= 0 && t.tokens[last].Type == kind {
+		t.tokens[last].Text += text
+		return
+	}
+	t.tokens = append(t.tokens, models.CodeToken{Type: kind, Text: text})
+}
+
+// scanString 返回以quote开头的字符串字面量的长度，支持反斜杠转义
+// 除反引号外，字符串在行尾结束，避免未闭合的引号吞掉后续代码
+func scanString(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// scanWhile 返回满足条件的前缀长度
+func scanWhile(s string, accept func(rune) bool) int {
+	for i, r := range s {
+		if !accept(r) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// isDigit 判断是否为ASCII数字
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
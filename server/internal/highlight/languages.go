@@ -0,0 +1,148 @@
+package highlight
+
+import "strings"
+
+// language 描述一种语言的词法规则
+type language struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string // 字符串定界符，反引号字符串可以跨行
+	tripleQuotes bool   // 是否支持Python风格的三引号字符串
+}
+
+// aliases 语言别名，映射到规范名称
+var aliases = map[string]string{
+	"go":         "go",
+	"golang":     "go",
+	"js":         "javascript",
+	"javascript": "javascript",
+	"jsx":        "javascript",
+	"ts":         "typescript",
+	"typescript": "typescript",
+	"tsx":        "typescript",
+	"py":         "python",
+	"python":     "python",
+	"java":       "java",
+	"c":          "c",
+	"h":          "c",
+	"cpp":        "cpp",
+	"c++":        "cpp",
+	"cc":         "cpp",
+	"rs":         "rust",
+	"rust":       "rust",
+	"sql":        "sql",
+	"sh":         "bash",
+	"bash":       "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"json":       "json",
+}
+
+// languages 支持高亮的语言
+var languages = map[string]*language{
+	"go": {
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var
+			true false nil iota bool byte error int int8 int16 int32 int64 uint uint8 uint16 uint32 uint64
+			uintptr float32 float64 complex64 complex128 rune string any append cap close copy delete len
+			make new panic print println recover`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"javascript": {
+		keywords: words(`break case catch class const continue debugger default delete do else export extends
+			finally for function if import in instanceof let new return super switch this throw try typeof
+			var void while with yield async await of static get set true false null undefined NaN Infinity`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"typescript": {
+		keywords: words(`break case catch class const continue debugger default delete do else export extends
+			finally for function if import in instanceof let new return super switch this throw try typeof
+			var void while with yield async await of static get set true false null undefined NaN Infinity
+			abstract as declare enum implements interface keyof namespace private protected public readonly
+			type any boolean never number object string symbol unknown`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"python": {
+		keywords: words(`and as assert async await break class continue def del elif else except finally for
+			from global if import in is lambda nonlocal not or pass raise return try while with yield
+			True False None self print len range int str float list dict set tuple`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		tripleQuotes: true,
+	},
+	"java": {
+		keywords: words(`abstract assert boolean break byte case catch char class const continue default do
+			double else enum extends final finally float for goto if implements import instanceof int
+			interface long native new package private protected public return short static strictfp super
+			switch synchronized this throw throws transient try void volatile while var true false null`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"c": {
+		keywords: words(`auto break case char const continue default do double else enum extern float for goto
+			if inline int long register restrict return short signed sizeof static struct switch typedef
+			union unsigned void volatile while NULL true false bool`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"cpp": {
+		keywords: words(`auto break case char const continue default do double else enum extern float for goto
+			if inline int long register return short signed sizeof static struct switch typedef union
+			unsigned void volatile while bool catch class constexpr delete explicit friend namespace new
+			noexcept nullptr operator private protected public template this throw try typename using
+			virtual override final true false std string vector`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"rust": {
+		keywords: words(`as async await break const continue crate dyn else enum extern false fn for if impl in
+			let loop match mod move mut pub ref return self Self static struct super trait true type unsafe
+			use where while i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64 bool char str
+			String Vec Option Some None Result Ok Err`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	},
+	"sql": {
+		keywords: words(`select from where insert into values update set delete create table drop alter add
+			index primary key foreign references join inner left right outer full on as and or not null
+			is in like between group by order having limit offset distinct union all exists case when then
+			else end count sum avg min max asc desc default unique view begin commit rollback
+			SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER ADD INDEX PRIMARY
+			KEY FOREIGN REFERENCES JOIN INNER LEFT RIGHT OUTER FULL ON AS AND OR NOT NULL IS IN LIKE BETWEEN
+			GROUP BY ORDER HAVING LIMIT OFFSET DISTINCT UNION ALL EXISTS CASE WHEN THEN ELSE END COUNT SUM
+			AVG MIN MAX ASC DESC DEFAULT UNIQUE VIEW BEGIN COMMIT ROLLBACK`),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+	},
+	"bash": {
+		keywords: words(`if then else elif fi case esac for while until do done in function return exit
+			local export readonly declare set unset shift echo cd source alias true false`),
+		lineComments: []string{"#"},
+		quotes:       "\"'`",
+	},
+	"json": {
+		keywords: words(`true false null`),
+		quotes:   "\"",
+	},
+}
+
+// words 将空白分隔的单词转换为集合
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
@@ -9,21 +9,107 @@ type User struct {
 	ID           string    `json:"id"`
 	SocketID     string    `json:"socket_id"`
 	Nickname     string    `json:"nickname"`
+	Tripcode     string    `json:"tripcode,omitempty"`
 	Avatar       string    `json:"avatar"`
 	JoinTime     time.Time `json:"join_time"`
 	LastActivity time.Time `json:"last_activity"`
 	IsOnline     bool      `json:"is_online"`
+	IsAdmin      bool      `json:"is_admin,omitempty"`
+	IsBot        bool      `json:"is_bot,omitempty"` // 机器人账号
+	SessionToken string    `json:"-"`
 }
 
 // Message 消息模型
 type Message struct {
-	ID           string    `json:"id"`
-	UserID       string    `json:"user_id"`
-	UserNickname string    `json:"user_nickname"`
-	UserAvatar   string    `json:"user_avatar"`
-	Content      string    `json:"content"`
-	Timestamp    time.Time `json:"timestamp"`
-	Type         string    `json:"type"` // text, system, emoji
+	ID           string             `json:"id"`
+	UserID       string             `json:"user_id"`
+	UserNickname string             `json:"user_nickname"`
+	UserTripcode string             `json:"user_tripcode,omitempty"`
+	UserAvatar   string             `json:"user_avatar"`
+	UserIsBot    bool               `json:"user_is_bot,omitempty"` // 发送者是机器人
+	Content      string             `json:"content"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Seq          uint64             `json:"seq,omitempty"`      // 广播该消息的房间事件序号
+	Type         string             `json:"type"`               // text, code, system, emoji
+	Language     string             `json:"language,omitempty"` // 代码块的语言标记
+	Tokens       []CodeToken        `json:"tokens,omitempty"`   // 代码块的高亮标记，语言不受支持时为空
+	Image        *ImageAsset        `json:"image,omitempty"`    // 图片消息引用的已上传图片
+	Reactions    []*Reaction        `json:"reactions,omitempty"`
+	Mentions     []string           `json:"mentions,omitempty"` // 被提及的用户ID
+	EditedAt     *time.Time         `json:"edited_at,omitempty"`
+	Deleted      bool               `json:"deleted,omitempty"` // 已撤回的消息只保留占位，内容为空
+	DeletedAt    *time.Time         `json:"deleted_at,omitempty"`
+	DeletedBy    string             `json:"deleted_by,omitempty"`
+	Revisions    []*MessageRevision `json:"-"` // 编辑历史，仅管理员可查看
+
+	ReplyTo string         `json:"reply_to,omitempty"` // 所属话题的根消息ID
+	Quote   *MessageQuote  `json:"quote,omitempty"`    // 被回复消息的引用，根消息过期后仍可展示
+	Thread  *ThreadSummary `json:"thread,omitempty"`   // 作为话题根消息时的回复汇总
+}
+
+// CodeToken 代码高亮标记，按顺序拼接所有标记的文本即为原始代码
+type CodeToken struct {
+	Type string `json:"type"` // plain, keyword, string, number, comment
+	Text string `json:"text"`
+}
+
+// ImageAsset 已上传并像素化的图片
+type ImageAsset struct {
+	ID     string `json:"id"` // 图片内容的SHA-256哈希
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// MessageRevision 消息的历史版本
+type MessageRevision struct {
+	Content  string    `json:"content"`
+	Action   string    `json:"action"` // edit, delete
+	EditedBy string    `json:"edited_by"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// MessageQuote 被回复消息的引用快照
+type MessageQuote struct {
+	ID           string `json:"id"`
+	UserID       string `json:"user_id"`
+	UserNickname string `json:"user_nickname"`
+	Content      string `json:"content"`
+	Type         string `json:"type"`
+	Deleted      bool   `json:"deleted,omitempty"`
+}
+
+// ThreadSummary 话题回复汇总
+type ThreadSummary struct {
+	ReplyCount      int       `json:"reply_count"`
+	LastReplyID     string    `json:"last_reply_id"`
+	LastReplyUserID string    `json:"last_reply_user_id"`
+	LastReplyAt     time.Time `json:"last_reply_at"`
+}
+
+// Reaction 消息上某个表情的回应汇总
+type Reaction struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// Emoji 表情目录中的表情
+type Emoji struct {
+	Code string `json:"code"`
+	Char string `json:"char"`
+	Name string `json:"name"`
+}
+
+// Room 聊天室的话题、公告和置顶消息
+type Room struct {
+	ID        string     `json:"id"`
+	Topic     string     `json:"topic"`
+	MOTD      string     `json:"motd"`       // 公告，如聊天室规则
+	PinnedIDs []string   `json:"pinned_ids"` // 置顶消息ID，按置顶顺序排列
+	Pinned    []*Message `json:"pinned"`     // 置顶消息快照，消息超出历史窗口后仍可展示
+	UpdatedAt time.Time  `json:"updated_at"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
 }
 
 // ChatStats 聊天室统计信息
@@ -35,33 +121,310 @@ type ChatStats struct {
 
 // JoinRequest 加入聊天室请求
 type JoinRequest struct {
-	Nickname string `json:"nickname"`
+	Nickname     string `json:"nickname"`
+	SessionToken string `json:"session_token,omitempty"` // 之前会话的令牌，用于重连时恢复身份
+	AdminToken   string `json:"admin_token,omitempty"`   // 管理员令牌，正确时获得管理员权限
+	LastSeq      uint64 `json:"last_seq,omitempty"`      // 重连时客户端收到的最后一个房间事件序号，用于补发错过的事件
+	BotToken     string `json:"bot_token,omitempty"`     // 机器人令牌，正确时以机器人身份加入，忽略昵称
 }
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	Type     string `json:"type,omitempty"`     // text（默认）、code 或 image
+	Language string `json:"language,omitempty"` // 代码块的语言，如 go、python
+	Image    string `json:"image,omitempty"`    // 图片消息引用的上传图片ID，content 为可选的说明文字
+	ReplyTo  string `json:"reply_to,omitempty"`
+	ClientID string `json:"client_id,omitempty"` // 客户端生成的消息ID，用于重发去重和匹配ack
+}
+
+// UpdateRoomRequest 修改聊天室话题和公告请求，未提供的字段保持不变
+type UpdateRoomRequest struct {
+	Topic *string `json:"topic,omitempty"`
+	MOTD  *string `json:"motd,omitempty"`
+}
+
+// PinMessageRequest 置顶或取消置顶消息请求
+type PinMessageRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// APIToken 用于脚本和CI发送消息的API令牌，令牌本身只在创建时返回一次
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`            // 发送消息时显示的昵称
+	UserID     string     `json:"user_id"`         // 发送消息时使用的用户ID
+	Avatar     string     `json:"avatar"`          // 发送消息时使用的头像
+	Scopes     []string   `json:"scopes"`          // 授权范围，如 messages:write
+	Rooms      []string   `json:"rooms,omitempty"` // 允许发送的聊天室，为空时不限制
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateAPITokenRequest 创建API令牌请求
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Rooms  []string `json:"rooms,omitempty"`
+}
+
+// CreateAPITokenResponse 创建API令牌响应，Secret只返回这一次
+type CreateAPITokenResponse struct {
+	Token  *APIToken `json:"token"`
+	Secret string    `json:"secret"`
+}
+
+// Bot 机器人账号，外部机器人使用令牌通过WebSocket连接，内置机器人在服务端进程内运行
+type Bot struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`    // 机器人的昵称
+	UserID          string     `json:"user_id"` // 机器人的用户ID，格式为 Bot#XXXXXXXX
+	Avatar          string     `json:"avatar"`
+	Builtin         bool       `json:"builtin,omitempty"` // 进程内运行的内置机器人，没有令牌
+	CreatedAt       time.Time  `json:"created_at"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	LastConnectedAt *time.Time `json:"last_connected_at,omitempty"`
+}
+
+// CreateBotRequest 创建机器人请求
+type CreateBotRequest struct {
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"` // 可选的头像编码，为空时按用户ID生成
+}
+
+// CreateBotResponse 创建机器人响应，Token只返回这一次
+type CreateBotResponse struct {
+	Bot   *Bot   `json:"bot"`
+	Token string `json:"token"`
+}
+
+// IncomingWebhook 传入webhook，外部系统通过带密钥的URL向聊天室发送消息
+type IncomingWebhook struct {
+	ID         string     `json:"id"`
+	RoomID     string     `json:"room_id"`
+	Name       string     `json:"name"`    // 默认显示的昵称，请求中的username可以覆盖
+	UserID     string     `json:"user_id"` // 发送消息时使用的用户ID
+	Avatar     string     `json:"avatar"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateIncomingWebhookRequest 创建传入webhook请求，Avatar为可选的头像编码
+type CreateIncomingWebhookRequest struct {
+	Name   string `json:"name"`
+	Room   string `json:"room,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// CreateIncomingWebhookResponse 创建传入webhook响应，URL中包含密钥，只返回这一次
+type CreateIncomingWebhookResponse struct {
+	Webhook *IncomingWebhook `json:"webhook"`
+	URL     string           `json:"url"`
+}
+
+// IncomingWebhookPayload 传入webhook的请求体，同时兼容Slack的 {"text": ...} 格式
+type IncomingWebhookPayload struct {
+	Content     string            `json:"content"`
+	Text        string            `json:"text"`     // Slack格式的消息内容，content为空时使用
+	Username    string            `json:"username"` // 覆盖本条消息显示的昵称
+	Type        string            `json:"type"`     // text 或 code
+	Language    string            `json:"language"`
+	ClientID    string            `json:"client_id"`
+	Attachments []SlackAttachment `json:"attachments"` // Slack附件，消息内容为空时使用
+}
+
+// OutgoingWebhook 传出webhook，聊天室事件发生时服务端向URL推送带签名的请求
+type OutgoingWebhook struct {
+	ID                  string     `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"` // 订阅的事件：message、join、leave、report
+	Active              bool       `json:"active"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"` // 连续失败后自动停用的原因
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastDeliveryAt      *time.Time `json:"last_delivery_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	CreatedBy           string     `json:"created_by"`
+}
+
+// CreateOutgoingWebhookRequest 创建传出webhook请求
+type CreateOutgoingWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// CreateOutgoingWebhookResponse 创建传出webhook响应，Secret用于校验签名，只返回这一次
+type CreateOutgoingWebhookResponse struct {
+	Webhook *OutgoingWebhook `json:"webhook"`
+	Secret  string           `json:"secret"`
+}
+
+// UpdateOutgoingWebhookRequest 修改传出webhook请求，未提供的字段保持不变，重新启用时清零失败次数
+type UpdateOutgoingWebhookRequest struct {
+	URL    *string  `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// WebhookDelivery 传出webhook的一次投递记录
+type WebhookDelivery struct {
+	DeliveryID string    `json:"delivery_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// WebhookPayload 传出webhook的请求体
+type WebhookPayload struct {
+	ID        string      `json:"id"` // 投递ID，重试时不变，接收方可据此去重
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// SlackAttachment Slack消息附件中可转换为文本的字段
+type SlackAttachment struct {
+	Fallback string `json:"fallback"`
+	Pretext  string `json:"pretext"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+}
+
+// ReportMessageRequest 举报消息请求
+type ReportMessageRequest struct {
+	MessageID string `json:"message_id"`
+	Reason    string `json:"reason"`
+}
+
+// MessageReport 消息举报，转发给订阅了report事件的传出webhook
+type MessageReport struct {
+	ID               string    `json:"id"`
+	MessageID        string    `json:"message_id"`
+	Message          *Message  `json:"message"` // 举报时的消息快照
+	ReporterID       string    `json:"reporter_id"`
+	ReporterNickname string    `json:"reporter_nickname"`
+	Reason           string    `json:"reason"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// MarkReadRequest 标记已读请求，message_id 为读到的最后一条消息
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}
+
+// DeleteMessageRequest 撤回消息请求
+type DeleteMessageRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// MessageHistoryRequest 获取消息编辑历史请求
+type MessageHistoryRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// GetThreadRequest 获取话题请求
+type GetThreadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// SetAvatarRequest 设置头像请求，Avatar为头像编码，PNG为待转换的PNG图片（JSON中为base64）
+type SetAvatarRequest struct {
+	Avatar string `json:"avatar,omitempty"`
+	PNG    []byte `json:"png,omitempty"`
+	Size   int    `json:"size,omitempty"`
+}
+
+// UpdateProfileRequest 修改资料请求，昵称和头像均为可选
+type UpdateProfileRequest struct {
+	Nickname      string `json:"nickname,omitempty"`
+	ClearTripcode bool   `json:"clear_tripcode,omitempty"` // 清除身份标识，不能与 nickname#secret 同时使用
+	SetAvatarRequest
+}
+
+// ReactRequest 表情回应请求，重复回应同一表情会取消
+type ReactRequest struct {
+	MessageID string `json:"message_id"`
+	Emoji     string `json:"emoji"`
 }
 
 // WebSocketMessage WebSocket消息
 type WebSocketMessage struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
+	Seq  uint64      `json:"seq,omitempty"` // 房间事件的序号，单调递增，直接回复和临时状态不带序号
+
+	RequestID string `json:"request_id,omitempty"` // 客户端请求ID，对该请求的直接回复会原样带回
+}
+
+// HelloRequest 握手请求，协商协议版本、功能和错误信息语言
+type HelloRequest struct {
+	Versions []int    `json:"versions,omitempty"` // 客户端支持的协议版本，为空时使用服务端当前版本
+	Features []string `json:"features,omitempty"` // 客户端希望启用的功能，为空时启用全部
+	Locale   string   `json:"locale,omitempty"`   // 错误信息语言，zh-CN（默认）或 en
+}
+
+// HelloResponse 握手响应
+type HelloResponse struct {
+	Version           int      `json:"version"`
+	SupportedVersions []int    `json:"supported_versions"`
+	Features          []string `json:"features"`
+	Locale            string   `json:"locale"`
 }
 
 // JoinResponse 加入聊天室响应
 type JoinResponse struct {
-	User     *User      `json:"user"`
-	Messages []*Message `json:"messages"`
+	User           *User           `json:"user"`
+	Messages       []*Message      `json:"messages"`
+	SessionToken   string          `json:"session_token"`
+	UnreadMentions *MentionSummary `json:"unread_mentions"`
+	Unread         *UnreadSummary  `json:"unread"`
+	Room           *Room           `json:"room"`
+	Seq            uint64          `json:"seq"` // 响应对应的房间事件序号，之后的事件会随后推送
+}
+
+// UnreadSummary 已读位置和未读消息数
+type UnreadSummary struct {
+	LastReadID string `json:"last_read_id"`
+	Count      int    `json:"count"`
+}
+
+// MentionSummary 未读提及汇总
+type MentionSummary struct {
+	Count      int      `json:"count"`
+	MessageIDs []string `json:"message_ids"`
+}
+
+// MentionedEvent 被提及事件，只发送给被提及的用户
+type MentionedEvent struct {
+	Message        *Message `json:"message"`
+	UnreadMentions int      `json:"unread_mentions"`
 }
 
 // UserJoinedEvent 用户加入事件
 type UserJoinedEvent struct {
-	User *User `json:"user"`
+	User      *User  `json:"user"`
+	MessageID string `json:"message_id,omitempty"` // 记录到历史中的系统消息ID，该消息的seq与本事件相同
 }
 
 // UserLeftEvent 用户离开事件
 type UserLeftEvent struct {
+	User      *User  `json:"user"`
+	MessageID string `json:"message_id,omitempty"` // 记录到历史中的系统消息ID，该消息的seq与本事件相同
+}
+
+// UserUpdatedEvent 用户资料更新事件
+type UserUpdatedEvent struct {
 	User *User `json:"user"`
 }
 
@@ -70,12 +433,93 @@ type NewMessageEvent struct {
 	Message *Message `json:"message"`
 }
 
+// ReactionUpdatedEvent 表情回应更新事件
+type ReactionUpdatedEvent struct {
+	MessageID string      `json:"message_id"`
+	Reactions []*Reaction `json:"reactions"`
+}
+
+// MessageEditedEvent 消息编辑事件
+type MessageEditedEvent struct {
+	Message *Message `json:"message"`
+}
+
+// MessageDeletedEvent 消息撤回事件，Message为撤回后的占位消息
+type MessageDeletedEvent struct {
+	MessageID string   `json:"message_id"`
+	Message   *Message `json:"message"`
+}
+
+// MessageHistoryResponse 消息编辑历史，仅管理员可查看
+type MessageHistoryResponse struct {
+	Message   *Message           `json:"message"`
+	Revisions []*MessageRevision `json:"revisions"`
+}
+
+// ThreadUpdatedEvent 话题回复汇总更新事件
+type ThreadUpdatedEvent struct {
+	MessageID string         `json:"message_id"`
+	Thread    *ThreadSummary `json:"thread"`
+}
+
+// QuoteUpdatedEvent 被引用的消息编辑或撤回后，引用了它的回复中的引用快照随之更新
+type QuoteUpdatedEvent struct {
+	MessageIDs []string      `json:"message_ids"` // 引用快照被更新的回复ID
+	Quote      *MessageQuote `json:"quote"`
+}
+
+// ThreadResponse 话题查询响应，根消息已过期时Parent为空且ParentEvicted为true
+type ThreadResponse struct {
+	ParentID      string     `json:"parent_id"`
+	Parent        *Message   `json:"parent"`
+	ParentEvicted bool       `json:"parent_evicted"`
+	Replies       []*Message `json:"replies"`
+}
+
+// TypingEvent 正在输入的用户
+type TypingEvent struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// ResyncRequiredEvent 错过的事件已无法补发，客户端需要重新同步
+type ResyncRequiredEvent struct {
+	LastSeq    uint64 `json:"last_seq"`
+	CurrentSeq uint64 `json:"current_seq"`
+}
+
+// RoomUpdatedEvent 聊天室信息更新事件
+type RoomUpdatedEvent struct {
+	Room *Room `json:"room"`
+}
+
+// MessageSeenEvent 消息的已读人数
+type MessageSeenEvent struct {
+	MessageID string `json:"message_id"`
+	SeenBy    int    `json:"seen_by"`
+}
+
 // UserListEvent 用户列表事件
 type UserListEvent struct {
 	Users []*User `json:"users"`
 }
 
+// AckEvent 消息发送成功的确认，只发送给发送者
+type AckEvent struct {
+	ClientID  string    `json:"client_id,omitempty"`
+	MessageID string    `json:"message_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Duplicate bool      `json:"duplicate,omitempty"` // 重复发送，消息之前已发送过，不会再次广播
+}
+
+// NackEvent 消息发送失败，只发送给发送者
+type NackEvent struct {
+	ClientID string `json:"client_id"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
 // ErrorEvent 错误事件
 type ErrorEvent struct {
-	Message string `json:"message"`
+	Code    string `json:"code"`    // 机器可读的错误码，如 USER_NOT_FOUND、RATE_LIMITED
+	Message string `json:"message"` // 按客户端语言本地化的错误信息
 }
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ScopeMessagesWrite 允许通过REST接口发送消息
+	ScopeMessagesWrite = "messages:write"
+
+	// apiTokenPrefix API令牌的前缀，便于在日志和密钥扫描中识别
+	apiTokenPrefix = "pct_"
+
+	// maxAPITokens 最多同时存在的API令牌数
+	maxAPITokens = 100
+)
+
+// apiScopes 可授予API令牌的授权范围
+var apiScopes = map[string]bool{
+	ScopeMessagesWrite: true,
+}
+
+// APITokenService 管理API令牌，只保存令牌的哈希
+type APITokenService struct {
+	tokens    map[string]*models.APIToken // 令牌ID -> 令牌
+	hashes    map[string]string           // 令牌哈希 -> 令牌ID
+	tokensMux sync.RWMutex
+}
+
+func NewAPITokenService() *APITokenService {
+	return &APITokenService{
+		tokens: make(map[string]*models.APIToken),
+		hashes: make(map[string]string),
+	}
+}
+
+// CreateToken 创建API令牌，返回令牌信息和只显示一次的令牌
+func (s *APITokenService) CreateToken(req *models.CreateAPITokenRequest, createdBy string) (*models.APIToken, string, error) {
+	name, err := NormalizeNickname(req.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", newChatError(CodeInvalidRequest, "请指定授权范围")
+	}
+	for _, scope := range req.Scopes {
+		if !apiScopes[scope] {
+			return nil, "", newChatError(CodeInvalidRequest, "不支持的授权范围: %s", scope)
+		}
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = apiTokenPrefix + secret
+
+	userID := "API#" + strings.ToUpper(id)
+	token := &models.APIToken{
+		ID:        id,
+		Name:      name,
+		UserID:    userID,
+		Avatar:    avatar.Generate(userID).String(),
+		Scopes:    append([]string(nil), req.Scopes...),
+		Rooms:     append([]string(nil), req.Rooms...),
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+	}
+
+	s.tokensMux.Lock()
+	defer s.tokensMux.Unlock()
+
+	if len(s.tokens) >= maxAPITokens {
+		return nil, "", newChatError(CodeInvalidRequest, "API令牌数量已达上限")
+	}
+	if _, exists := s.tokens[id]; exists {
+		return nil, "", newChatError(CodeInternal, "生成API令牌失败，请重试")
+	}
+	s.tokens[id] = token
+	s.hashes[hashSecret(secret)] = id
+	return token, secret, nil
+}
+
+// Authenticate 校验令牌并记录使用时间
+func (s *APITokenService) Authenticate(secret string) (*models.APIToken, bool) {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return nil, false
+	}
+
+	s.tokensMux.Lock()
+	defer s.tokensMux.Unlock()
+
+	id, exists := s.hashes[hashSecret(secret)]
+	if !exists {
+		return nil, false
+	}
+
+	// 复制后再修改，已返回的令牌信息保持不变
+	now := time.Now()
+	updated := *s.tokens[id]
+	updated.LastUsedAt = &now
+	s.tokens[id] = &updated
+	return &updated, true
+}
+
+// ListTokens 按创建时间列出API令牌
+func (s *APITokenService) ListTokens() []*models.APIToken {
+	s.tokensMux.RLock()
+	defer s.tokensMux.RUnlock()
+
+	tokens := make([]*models.APIToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// RevokeToken 吊销API令牌
+func (s *APITokenService) RevokeToken(id string) error {
+	s.tokensMux.Lock()
+	defer s.tokensMux.Unlock()
+
+	if _, exists := s.tokens[id]; !exists {
+		return newChatError(CodeTokenNotFound, "API令牌不存在")
+	}
+	delete(s.tokens, id)
+	for hash, tokenID := range s.hashes {
+		if tokenID == id {
+			delete(s.hashes, hash)
+		}
+	}
+	return nil
+}
+
+// hasScope 令牌是否拥有指定的授权范围
+func hasScope(token *models.APIToken, scope string) bool {
+	for _, granted := range token.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRoom 令牌是否允许访问指定聊天室，未限制聊天室时允许全部
+func allowsRoom(token *models.APIToken, roomID string) bool {
+	if len(token.Rooms) == 0 {
+		return true
+	}
+	for _, room := range token.Rooms {
+		if room == roomID {
+			return true
+		}
+	}
+	return false
+}
+
+// hashSecret 计算令牌或密钥的哈希，令牌本身不落地保存
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex 生成n字节随机数的16进制表示
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", newChatError(CodeInternal, "生成随机令牌失败")
+	}
+	return hex.EncodeToString(buf), nil
+}
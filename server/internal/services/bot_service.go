@@ -0,0 +1,177 @@
+package services
+
+import (
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// botTokenPrefix 机器人令牌的前缀，便于与API令牌区分
+	botTokenPrefix = "pcb_"
+
+	// maxBots 最多同时存在的机器人数，包括内置机器人
+	maxBots = 100
+)
+
+// BotService 管理机器人账号，只保存令牌的哈希
+type BotService struct {
+	bots    map[string]*models.Bot // 机器人ID -> 机器人
+	hashes  map[string]string      // 令牌哈希 -> 机器人ID
+	botsMux sync.RWMutex
+}
+
+func NewBotService() *BotService {
+	return &BotService{
+		bots:   make(map[string]*models.Bot),
+		hashes: make(map[string]string),
+	}
+}
+
+// CreateBot 创建使用令牌连接的机器人，返回机器人信息和只显示一次的令牌
+func (s *BotService) CreateBot(req *models.CreateBotRequest, createdBy string) (*models.Bot, string, error) {
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = botTokenPrefix + secret
+
+	bot, err := newBot(id, req, createdBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.botsMux.Lock()
+	defer s.botsMux.Unlock()
+
+	if err := s.addLocked(bot); err != nil {
+		return nil, "", err
+	}
+	s.hashes[hashSecret(secret)] = id
+	return bot, secret, nil
+}
+
+// RegisterBuiltin 注册进程内运行的内置机器人，ID由名称得出，重启后保持不变
+func (s *BotService) RegisterBuiltin(name string) (*models.Bot, error) {
+	id := hashSecret("builtin:" + strings.ToLower(name))[:8]
+	bot, err := newBot(id, &models.CreateBotRequest{Name: name}, "")
+	if err != nil {
+		return nil, err
+	}
+	bot.Builtin = true
+
+	s.botsMux.Lock()
+	defer s.botsMux.Unlock()
+
+	if err := s.addLocked(bot); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// Authenticate 校验机器人令牌并记录连接时间
+func (s *BotService) Authenticate(secret string) (*models.Bot, bool) {
+	if !strings.HasPrefix(secret, botTokenPrefix) {
+		return nil, false
+	}
+
+	s.botsMux.Lock()
+	defer s.botsMux.Unlock()
+
+	id, exists := s.hashes[hashSecret(secret)]
+	if !exists {
+		return nil, false
+	}
+
+	// 复制后再修改，已返回的机器人信息保持不变
+	now := time.Now()
+	updated := *s.bots[id]
+	updated.LastConnectedAt = &now
+	s.bots[id] = &updated
+	return &updated, true
+}
+
+// ListBots 按创建时间列出机器人
+func (s *BotService) ListBots() []*models.Bot {
+	s.botsMux.RLock()
+	defer s.botsMux.RUnlock()
+
+	bots := make([]*models.Bot, 0, len(s.bots))
+	for _, bot := range s.bots {
+		bots = append(bots, bot)
+	}
+	sort.Slice(bots, func(i, j int) bool {
+		return bots[i].CreatedAt.Before(bots[j].CreatedAt)
+	})
+	return bots
+}
+
+// DeleteBot 删除机器人并吊销其令牌，内置机器人只能通过配置停用
+func (s *BotService) DeleteBot(id string) error {
+	s.botsMux.Lock()
+	defer s.botsMux.Unlock()
+
+	bot, exists := s.bots[id]
+	if !exists {
+		return newChatError(CodeBotNotFound, "机器人不存在")
+	}
+	if bot.Builtin {
+		return newChatError(CodeForbidden, "内置机器人不能删除")
+	}
+	delete(s.bots, id)
+	for hash, botID := range s.hashes {
+		if botID == id {
+			delete(s.hashes, hash)
+		}
+	}
+	return nil
+}
+
+// addLocked 保存机器人，调用方需持有写锁
+func (s *BotService) addLocked(bot *models.Bot) error {
+	if len(s.bots) >= maxBots {
+		return newChatError(CodeInvalidRequest, "机器人数量已达上限")
+	}
+	if _, exists := s.bots[bot.ID]; exists {
+		if bot.Builtin {
+			return newChatError(CodeInvalidRequest, "内置机器人 %s 重复注册", bot.Name)
+		}
+		return newChatError(CodeInternal, "生成机器人失败，请重试")
+	}
+	s.bots[bot.ID] = bot
+	return nil
+}
+
+// newBot 校验昵称和头像并生成机器人信息
+func newBot(id string, req *models.CreateBotRequest, createdBy string) (*models.Bot, error) {
+	name, err := NormalizeNickname(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := "Bot#" + strings.ToUpper(id)
+	encoded := avatar.Generate(userID).String()
+	if req.Avatar != "" {
+		decoded, err := avatar.Decode(req.Avatar)
+		if err != nil {
+			return nil, &ChatError{Code: CodeInvalidAvatar, Message: err.Error()}
+		}
+		encoded = decoded.String()
+	}
+
+	return &models.Bot{
+		ID:        id,
+		Name:      name,
+		UserID:    userID,
+		Avatar:    encoded,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+	}, nil
+}
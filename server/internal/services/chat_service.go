@@ -1,73 +1,687 @@
 package services
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/highlight"
 	"pixel-chat-server/internal/models"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// profileUpdateLimit 每个用户在profileUpdateWindow内最多修改资料的次数
+	profileUpdateLimit  = 5
+	profileUpdateWindow = time.Minute
+
+	// uploadLimit 每个用户在uploadWindow内最多上传图片的次数
+	uploadLimit  = 10
+	uploadWindow = time.Minute
+
+	// typingLimit 每个用户在typingWindow内最多发送typing_start的次数，客户端通常每几秒重发一次
+	typingLimit  = 30
+	typingWindow = time.Minute
+
+	// sendWindow 发送限流的统计窗口，每个窗口的条数由ChatOptions.SendLimit配置，WebSocket、API令牌和webhook共用
+	sendWindow = time.Minute
+
+	// reportLimit 每个用户在reportWindow内最多举报的次数
+	reportLimit  = 10
+	reportWindow = time.Minute
+
+	// maxReportReasonLength 举报理由的最大字符数
+	maxReportReasonLength = 200
+
+	// maxLanguageLength 代码块语言标记的最大长度
+	maxLanguageLength = 20
 )
 
+// ChatOptions 聊天服务配置
+type ChatOptions struct {
+	AdminToken string        // 管理员令牌，加入时携带即获得管理员权限，为空时不启用
+	EditWindow time.Duration // 作者可编辑或撤回自己消息的时限，管理员不受限制
+	SendLimit  int           // 每个发送者每分钟最多发送的消息数，0表示不限制
+}
+
 type ChatService struct {
-	userService    *UserService
-	messageService *MessageService
-	startTime      time.Time
+	userService     *UserService
+	messageService  *MessageService
+	tripcodeService *TripcodeService
+	mentionService  *MentionService
+	uploadService   *UploadService
+	readMarkers     *ReadMarkerService
+	roomService     *RoomService
+	apiTokens       *APITokenService
+	webhooks        *IncomingWebhookService
+	outgoing        *OutgoingWebhookService
+	bots            *BotService
+	profileLimiter  *RateLimiter
+	uploadLimiter   *RateLimiter
+	sendDedup       *SendDeduplicator
+	typingLimiter   *RateLimiter
+	sendLimiter     *RateLimiter // 为空时不限制发送频率
+	reportLimiter   *RateLimiter
+	options         ChatOptions
+	startTime       time.Time
 }
 
-func NewChatService(userService *UserService, messageService *MessageService) *ChatService {
-	return &ChatService{
-		userService:    userService,
-		messageService: messageService,
-		startTime:      time.Now(),
+func NewChatService(userService *UserService, messageService *MessageService, tripcodeService *TripcodeService, mentionService *MentionService, uploadService *UploadService, readMarkers *ReadMarkerService, roomService *RoomService, apiTokens *APITokenService, webhooks *IncomingWebhookService, outgoing *OutgoingWebhookService, bots *BotService, options ChatOptions) *ChatService {
+	s := &ChatService{
+		userService:     userService,
+		messageService:  messageService,
+		tripcodeService: tripcodeService,
+		mentionService:  mentionService,
+		uploadService:   uploadService,
+		readMarkers:     readMarkers,
+		roomService:     roomService,
+		apiTokens:       apiTokens,
+		webhooks:        webhooks,
+		outgoing:        outgoing,
+		bots:            bots,
+		profileLimiter:  NewRateLimiter(profileUpdateLimit, profileUpdateWindow),
+		uploadLimiter:   NewRateLimiter(uploadLimit, uploadWindow),
+		sendDedup:       NewSendDeduplicator(),
+		typingLimiter:   NewRateLimiter(typingLimit, typingWindow),
+		reportLimiter:   NewRateLimiter(reportLimit, reportWindow),
+		options:         options,
+		startTime:       time.Now(),
+	}
+	if options.SendLimit > 0 {
+		s.sendLimiter = NewRateLimiter(options.SendLimit, sendWindow)
 	}
+	return s
 }
 
-// AddUser 添加用户到聊天室
-func (s *ChatService) AddUser(socketID string, nickname string) (*models.User, error) {
-	user, err := s.userService.CreateUser(socketID, nickname)
+// AddUser 添加用户到聊天室，返回用户和记录到历史中的系统消息
+// 昵称可以使用 nickname#secret 形式附带身份口令，携带之前的会话令牌可以在重连时恢复身份，携带机器人令牌时以机器人身份加入
+func (s *ChatService) AddUser(socketID string, req *models.JoinRequest) (*models.User, *models.Message, error) {
+	params, err := s.joinParams(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	user, err := s.userService.CreateUser(socketID, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 新分配的用户ID可能曾属于其他用户，清除遗留的提及记录
+	if user.SessionToken != req.SessionToken {
+		s.mentionService.Clear(user.ID)
 	}
+	// 资料已过期的用户无法再恢复身份，他们的未读提及随之清理
+	s.mentionService.Prune(func(userID string) bool {
+		_, exists := s.userService.GetProfile(userID)
+		return exists
+	})
+
+	// 首次出现的身份从当前位置开始计算未读，之前的历史视为已读
+	latestID := ""
+	if latest, exists := s.messageService.GetLatestMessage(); exists {
+		latestID = latest.ID
+	}
+	s.readMarkers.Init(readIdentity(user), latestID, time.Now())
 
 	// 添加系统消息
-	s.messageService.AddSystemMessage(fmt.Sprintf("用户 %s 加入了聊天室", user.Nickname))
+	systemMessage := s.messageService.AddSystemMessage(fmt.Sprintf("用户 %s 加入了聊天室", user.Nickname))
+	s.outgoing.Dispatch(WebhookEventJoin, models.UserJoinedEvent{User: user})
 
-	return user, nil
+	return user, systemMessage, nil
 }
 
-// RemoveUser 从聊天室移除用户
-func (s *ChatService) RemoveUser(socketID string) *models.User {
+// joinParams 根据加入请求生成用户参数
+func (s *ChatService) joinParams(req *models.JoinRequest) (UserParams, error) {
+	if req.BotToken != "" {
+		bot, exists := s.bots.Authenticate(req.BotToken)
+		if !exists {
+			return UserParams{}, newChatError(CodeForbidden, "机器人令牌无效")
+		}
+		return UserParams{Nickname: bot.Name, Bot: bot}, nil
+	}
+
+	// 加入时与 update_profile 使用相同的昵称规则，避免以保留昵称冒充系统
+	nickname, tripcode, err := s.parseNickname(req.Nickname)
+	if err != nil {
+		return UserParams{}, err
+	}
+	return UserParams{
+		Nickname:    nickname,
+		Tripcode:    tripcode,
+		ResumeToken: req.SessionToken,
+		IsAdmin:     s.IsAdminToken(req.AdminToken),
+	}, nil
+}
+
+// RemoveUser 从聊天室移除用户，返回离开的用户和记录到历史中的系统消息
+func (s *ChatService) RemoveUser(socketID string) (*models.User, *models.Message) {
 	user := s.userService.RemoveUser(socketID)
-	if user != nil {
-		// 添加系统消息
-		s.messageService.AddSystemMessage(fmt.Sprintf("用户 %s 离开了聊天室", user.Nickname))
+	if user == nil {
+		return nil, nil
 	}
-	return user
+
+	// 添加系统消息
+	systemMessage := s.messageService.AddSystemMessage(fmt.Sprintf("用户 %s 离开了聊天室", user.Nickname))
+	s.outgoing.Dispatch(WebhookEventLeave, models.UserLeftEvent{User: user})
+	return user, systemMessage
 }
 
-// SendMessage 发送消息
-func (s *ChatService) SendMessage(socketID string, content string) (*models.Message, error) {
+// SendMessage 发送消息，可以通过ReplyTo回复历史中的消息
+// 携带ClientID时在去重窗口内只发送一次，重复请求返回首次发送的消息且duplicate为true
+func (s *ChatService) SendMessage(socketID string, req *models.SendMessageRequest) (message *models.Message, duplicate bool, err error) {
 	user, exists := s.userService.GetUser(socketID)
 	if !exists {
-		return nil, fmt.Errorf("用户不存在，请重新加入聊天室")
+		return nil, false, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
 	}
 
 	// 更新用户活动时间
 	s.userService.UpdateUserActivity(socketID)
 
+	return s.sendAs(user, req)
+}
+
+// PostMessageWithToken 以API令牌的身份向聊天室发送消息，校验、去重和限流与SendMessage相同
+func (s *ChatService) PostMessageWithToken(token *models.APIToken, roomID string, req *models.SendMessageRequest) (message *models.Message, duplicate bool, err error) {
+	if !hasScope(token, ScopeMessagesWrite) {
+		return nil, false, newChatError(CodeForbidden, "API令牌没有发送消息的权限")
+	}
+	if _, exists := s.roomService.GetRoom(roomID); !exists {
+		return nil, false, newChatError(CodeRoomNotFound, "聊天室不存在")
+	}
+	if !allowsRoom(token, roomID) {
+		return nil, false, newChatError(CodeForbidden, "API令牌不能向该聊天室发送消息")
+	}
+
+	author := &models.User{
+		ID:       token.UserID,
+		Nickname: token.Name,
+		Avatar:   token.Avatar,
+	}
+	return s.sendAs(author, req)
+}
+
+// PostWebhookMessage 通过传入webhook发送消息，校验、去重和限流与SendMessage相同
+// 密钥错误和webhook不存在返回相同的错误，避免泄露webhook是否存在
+func (s *ChatService) PostWebhookMessage(id, secret string, payload *models.IncomingWebhookPayload) (message *models.Message, duplicate bool, err error) {
+	webhook, exists := s.webhooks.Authenticate(id, secret)
+	if !exists {
+		return nil, false, newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+	if _, exists := s.roomService.GetRoom(webhook.RoomID); !exists {
+		return nil, false, newChatError(CodeRoomNotFound, "聊天室不存在")
+	}
+	if payload.Type != "" && payload.Type != "text" && payload.Type != "code" {
+		return nil, false, newChatError(CodeInvalidRequest, "webhook只能发送文本或代码消息")
+	}
+
+	author := &models.User{
+		ID:       webhook.UserID,
+		Nickname: webhook.Name,
+		Avatar:   webhook.Avatar,
+	}
+	if payload.Username != "" {
+		nickname, err := NormalizeNickname(payload.Username)
+		if err != nil {
+			return nil, false, err
+		}
+		author.Nickname = nickname
+	}
+
+	return s.sendAs(author, &models.SendMessageRequest{
+		Content:  webhookContent(payload),
+		Type:     payload.Type,
+		Language: payload.Language,
+		ClientID: payload.ClientID,
+	})
+}
+
+// PostBotMessage 以内置机器人的身份发送消息，校验、去重和限流与SendMessage相同
+func (s *ChatService) PostBotMessage(bot *models.Bot, req *models.SendMessageRequest) (message *models.Message, duplicate bool, err error) {
+	author := &models.User{
+		ID:       bot.UserID,
+		Nickname: bot.Name,
+		Avatar:   bot.Avatar,
+		IsBot:    true,
+	}
+	return s.sendAs(author, req)
+}
+
+// sendAs 以指定用户的身份发送消息，携带ClientID时按用户ID去重
+// 未恢复会话的重连会分配新的用户ID，之前的ClientID不再去重，客户端需携带session_token重连
+func (s *ChatService) sendAs(user *models.User, req *models.SendMessageRequest) (message *models.Message, duplicate bool, err error) {
+	if req.ClientID == "" {
+		message, err := s.postMessage(user, req)
+		return message, false, err
+	}
+	if len(req.ClientID) > maxClientIDLength {
+		return nil, false, newChatError(CodeInvalidRequest, "客户端消息ID最多%d个字符", maxClientIDLength)
+	}
+	return s.sendDedup.Do(user.ID+"/"+req.ClientID, func() (*models.Message, error) {
+		return s.postMessage(user, req)
+	})
+}
+
+// postMessage 以指定用户的身份添加消息
+func (s *ChatService) postMessage(user *models.User, req *models.SendMessageRequest) (*models.Message, error) {
+	if s.sendLimiter != nil && !s.sendLimiter.Allow(user.ID) {
+		return nil, newChatError(CodeRateLimited, "发送消息过于频繁，请稍后再试")
+	}
+
 	// 添加消息
-	message, err := s.messageService.AddMessage(
-		user.ID,
-		user.Nickname,
-		user.Avatar,
-		content,
-		"text",
-	)
+	var message *models.Message
+	switch req.Type {
+	case "", "text":
+		message = newMessage(user, req.Content, "text")
+		message.Mentions = ParseMentions(req.Content, user.ID, s.userService.GetProfiles())
+	case "code":
+		// 代码块原样保存，不解析提及
+		language, err := parseLanguage(req.Language)
+		if err != nil {
+			return nil, err
+		}
+		message = newMessage(user, req.Content, "code")
+		message.Language = language
+	case "image":
+		asset, exists := s.uploadService.Get(req.Image)
+		if !exists {
+			return nil, newChatError(CodeImageNotFound, "图片不存在，请先上传")
+		}
+		message = newMessage(user, req.Content, "image")
+		message.Image = asset
+		message.Mentions = ParseMentions(req.Content, user.ID, s.userService.GetProfiles())
+	default:
+		return nil, newChatError(CodeInvalidRequest, "不支持的消息类型")
+	}
+	message.ReplyTo = req.ReplyTo
+	message, err := s.messageService.AddMessage(message)
 	if err != nil {
 		return nil, err
 	}
 
+	s.mentionService.Record(message.Mentions, message.ID)
+	s.outgoing.Dispatch(WebhookEventMessage, models.NewMessageEvent{Message: message})
+	// 发送消息意味着已读到当前位置，API令牌等没有会话的发送者不记录
+	if identity := readIdentity(user); identity != "" {
+		s.readMarkers.Mark(identity, message.ID, message.Timestamp)
+	}
 	return message, nil
 }
 
+// ReportMessage 举报消息，举报转发给订阅了report事件的传出webhook
+func (s *ChatService) ReportMessage(socketID string, req *models.ReportMessageRequest) (*models.MessageReport, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		return nil, newChatError(CodeInvalidRequest, "举报理由最多%d个字符", maxReportReasonLength)
+	}
+	message, exists := s.messageService.GetMessage(req.MessageID)
+	if !exists {
+		return nil, newChatError(CodeMessageNotFound, "消息不存在或已超出历史范围")
+	}
+	if !s.reportLimiter.Allow(user.ID) {
+		return nil, newChatError(CodeRateLimited, "举报过于频繁，请稍后再试")
+	}
+
+	report := &models.MessageReport{
+		ID:               uuid.New().String(),
+		MessageID:        message.ID,
+		Message:          message,
+		ReporterID:       user.ID,
+		ReporterNickname: user.Nickname,
+		Reason:           reason,
+		CreatedAt:        time.Now(),
+	}
+	s.outgoing.Dispatch(WebhookEventReport, report)
+	return report, nil
+}
+
+// MarkRead 将用户的已读位置前移到指定消息，位置有变化时返回该消息的已读人数
+func (s *ChatService) MarkRead(socketID string, req *models.MarkReadRequest) (*models.UnreadSummary, *models.MessageSeenEvent, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	message, exists := s.messageService.GetMessage(req.MessageID)
+	if !exists {
+		return nil, nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+
+	var seen *models.MessageSeenEvent
+	if s.readMarkers.Mark(readIdentity(user), message.ID, message.Timestamp) {
+		seen = &models.MessageSeenEvent{
+			MessageID: message.ID,
+			SeenBy:    s.readMarkers.SeenBy(message.Timestamp, s.onlineIdentities()),
+		}
+	}
+	return s.GetUnread(user), seen, nil
+}
+
+// onlineIdentities 返回在线用户的已读身份
+func (s *ChatService) onlineIdentities() map[string]bool {
+	identities := make(map[string]bool)
+	for _, user := range s.userService.GetOnlineUsers() {
+		if identity := readIdentity(user); identity != "" {
+			identities[identity] = true
+		}
+	}
+	return identities
+}
+
+// GetUnread 获取用户的已读位置和未读消息数
+func (s *ChatService) GetUnread(user *models.User) *models.UnreadSummary {
+	lastReadID, timestamp, exists := s.readMarkers.Get(readIdentity(user))
+	if !exists {
+		return &models.UnreadSummary{}
+	}
+	return &models.UnreadSummary{
+		LastReadID: lastReadID,
+		Count:      s.messageService.CountUnread(timestamp, user.ID),
+	}
+}
+
+// UploadImage 上传图片，图片会被像素化后保存，返回的图片ID可用于发送图片消息
+func (s *ChatService) UploadImage(socketID string, data []byte) (*models.ImageAsset, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+	if !s.uploadLimiter.Allow(user.ID) {
+		return nil, newChatError(CodeRateLimited, "上传过于频繁，请稍后再试")
+	}
+
+	s.userService.UpdateUserActivity(socketID)
+	return s.uploadService.Save(data)
+}
+
+// AllowTyping 校验输入状态更新，typing_start受频率限制，typing_stop只会减少广播因此不限制
+func (s *ChatService) AllowTyping(socketID string, typing bool) (*models.User, bool) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, false
+	}
+	if typing && !s.typingLimiter.Allow(user.ID) {
+		return nil, false
+	}
+	return user, true
+}
+
+// GetUploadPath 获取已上传图片的本地路径
+func (s *ChatService) GetUploadPath(id string) (string, bool) {
+	if _, exists := s.uploadService.Get(id); !exists {
+		return "", false
+	}
+	return s.uploadService.Path(id), true
+}
+
+// GetUnreadMentions 获取用户的未读提及
+func (s *ChatService) GetUnreadMentions(userID string) *models.MentionSummary {
+	return s.mentionService.GetUnread(userID)
+}
+
+// ClearMentions 将用户的提及全部标记为已读
+func (s *ChatService) ClearMentions(socketID string) error {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	s.mentionService.Clear(user.ID)
+	return nil
+}
+
+// EditMessage 编辑消息，作者只能在时限内编辑自己的消息，管理员不受限制
+// 文本和图片消息按新内容重新解析提及，新提及的用户记录为未读
+func (s *ChatService) EditMessage(socketID string, req *models.EditMessageRequest) (*Revision, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+	original, exists := s.messageService.GetMessage(req.MessageID)
+	if !exists {
+		return nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+
+	s.userService.UpdateUserActivity(socketID)
+	mentions := ParseMentions(req.Content, original.UserID, s.userService.GetProfiles())
+	revision, err := s.messageService.EditMessage(req.MessageID, user.ID, req.Content, mentions, s.authorizeRevision(user))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mentionService.Record(revision.NewMentions, revision.Message.ID)
+	s.roomService.RefreshPinned(revision.Message)
+	return revision, nil
+}
+
+// DeleteMessage 撤回消息，作者只能在时限内撤回自己的消息，管理员不受限制
+func (s *ChatService) DeleteMessage(socketID string, req *models.DeleteMessageRequest) (*Revision, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	s.userService.UpdateUserActivity(socketID)
+	revision, err := s.messageService.DeleteMessage(req.MessageID, user.ID, s.authorizeRevision(user))
+	if err != nil {
+		return nil, err
+	}
+
+	s.roomService.RefreshPinned(revision.Message)
+	return revision, nil
+}
+
+// SetMessageSeq 记录消息广播时分配的房间事件序号
+func (s *ChatService) SetMessageSeq(messageID string, seq uint64) {
+	s.messageService.SetMessageSeq(messageID, seq)
+}
+
+// GetRoom 获取聊天室的话题、公告和置顶消息
+func (s *ChatService) GetRoom() *models.Room {
+	room, _ := s.roomService.GetRoom(DefaultRoomID)
+	return room
+}
+
+// UpdateRoom 修改聊天室话题和公告，调用方需校验管理员权限
+func (s *ChatService) UpdateRoom(actorID string, req *models.UpdateRoomRequest) (*models.Room, error) {
+	return s.roomService.UpdateRoom(DefaultRoomID, actorID, req)
+}
+
+// PinMessage 置顶消息，调用方需校验管理员权限
+func (s *ChatService) PinMessage(actorID string, req *models.PinMessageRequest) (*models.Room, error) {
+	message, exists := s.messageService.GetMessage(req.MessageID)
+	if !exists {
+		return nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+	return s.roomService.PinMessage(DefaultRoomID, actorID, message)
+}
+
+// UnpinMessage 取消置顶，调用方需校验管理员权限
+func (s *ChatService) UnpinMessage(actorID string, req *models.PinMessageRequest) (*models.Room, error) {
+	return s.roomService.UnpinMessage(DefaultRoomID, actorID, req.MessageID)
+}
+
+// GetMessageHistory 获取消息的编辑历史，供管理员审核
+func (s *ChatService) GetMessageHistory(messageID string) (*models.MessageHistoryResponse, error) {
+	message, revisions, err := s.messageService.GetRevisions(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MessageHistoryResponse{
+		Message:   message,
+		Revisions: revisions,
+	}, nil
+}
+
+// CreateAPIToken 创建API令牌，限制的聊天室必须存在
+func (s *ChatService) CreateAPIToken(req *models.CreateAPITokenRequest, createdBy string) (*models.APIToken, string, error) {
+	for _, roomID := range req.Rooms {
+		if _, exists := s.roomService.GetRoom(roomID); !exists {
+			return nil, "", newChatError(CodeRoomNotFound, "聊天室不存在: %s", roomID)
+		}
+	}
+	return s.apiTokens.CreateToken(req, createdBy)
+}
+
+// CreateIncomingWebhook 创建传入webhook，未指定聊天室时使用默认聊天室
+func (s *ChatService) CreateIncomingWebhook(req *models.CreateIncomingWebhookRequest, createdBy string) (*models.IncomingWebhook, string, error) {
+	roomID := req.Room
+	if roomID == "" {
+		roomID = DefaultRoomID
+	}
+	if _, exists := s.roomService.GetRoom(roomID); !exists {
+		return nil, "", newChatError(CodeRoomNotFound, "聊天室不存在: %s", roomID)
+	}
+	return s.webhooks.CreateWebhook(roomID, req, createdBy)
+}
+
+// ListIncomingWebhooks 列出传入webhook
+func (s *ChatService) ListIncomingWebhooks() []*models.IncomingWebhook {
+	return s.webhooks.ListWebhooks()
+}
+
+// DeleteIncomingWebhook 删除传入webhook
+func (s *ChatService) DeleteIncomingWebhook(id string) error {
+	return s.webhooks.DeleteWebhook(id)
+}
+
+// CreateBot 创建使用令牌连接的机器人
+func (s *ChatService) CreateBot(req *models.CreateBotRequest, createdBy string) (*models.Bot, string, error) {
+	return s.bots.CreateBot(req, createdBy)
+}
+
+// RegisterBuiltinBot 注册进程内运行的内置机器人
+func (s *ChatService) RegisterBuiltinBot(name string) (*models.Bot, error) {
+	return s.bots.RegisterBuiltin(name)
+}
+
+// ListBots 列出机器人
+func (s *ChatService) ListBots() []*models.Bot {
+	return s.bots.ListBots()
+}
+
+// DeleteBot 删除机器人并吊销令牌，已连接的机器人在断开前不受影响
+func (s *ChatService) DeleteBot(id string) error {
+	return s.bots.DeleteBot(id)
+}
+
+// CreateOutgoingWebhook 注册传出webhook
+func (s *ChatService) CreateOutgoingWebhook(req *models.CreateOutgoingWebhookRequest, createdBy string) (*models.OutgoingWebhook, string, error) {
+	return s.outgoing.CreateWebhook(req, createdBy)
+}
+
+// UpdateOutgoingWebhook 修改传出webhook
+func (s *ChatService) UpdateOutgoingWebhook(id string, req *models.UpdateOutgoingWebhookRequest) (*models.OutgoingWebhook, error) {
+	return s.outgoing.UpdateWebhook(id, req)
+}
+
+// DeleteOutgoingWebhook 删除传出webhook
+func (s *ChatService) DeleteOutgoingWebhook(id string) error {
+	return s.outgoing.DeleteWebhook(id)
+}
+
+// ListOutgoingWebhooks 列出传出webhook
+func (s *ChatService) ListOutgoingWebhooks() []*models.OutgoingWebhook {
+	return s.outgoing.ListWebhooks()
+}
+
+// GetWebhookDeliveries 获取传出webhook最近的投递记录
+func (s *ChatService) GetWebhookDeliveries(id string) ([]*models.WebhookDelivery, error) {
+	return s.outgoing.Deliveries(id)
+}
+
+// PingOutgoingWebhook 向传出webhook发送测试事件
+func (s *ChatService) PingOutgoingWebhook(id string) error {
+	return s.outgoing.Ping(id)
+}
+
+// AuthenticateAPIToken 校验API令牌
+func (s *ChatService) AuthenticateAPIToken(secret string) (*models.APIToken, bool) {
+	return s.apiTokens.Authenticate(secret)
+}
+
+// ListAPITokens 列出API令牌
+func (s *ChatService) ListAPITokens() []*models.APIToken {
+	return s.apiTokens.ListTokens()
+}
+
+// RevokeAPIToken 吊销API令牌
+func (s *ChatService) RevokeAPIToken(id string) error {
+	return s.apiTokens.RevokeToken(id)
+}
+
+// IsAdminToken 校验管理员令牌
+func (s *ChatService) IsAdminToken(token string) bool {
+	if s.options.AdminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.options.AdminToken)) == 1
+}
+
+// authorizeRevision 返回校验用户能否编辑或撤回消息的函数
+func (s *ChatService) authorizeRevision(user *models.User) func(message *models.Message) error {
+	return func(message *models.Message) error {
+		if user.IsAdmin {
+			return nil
+		}
+		if message.UserID != user.ID {
+			return newChatError(CodeForbidden, "只能修改自己的消息")
+		}
+		if time.Since(message.Timestamp) > s.options.EditWindow {
+			return newChatError(CodeEditWindowExpired, "已超过可修改的时限")
+		}
+		return nil
+	}
+}
+
+// GetMessage 根据ID获取消息
+func (s *ChatService) GetMessage(messageID string) (*models.Message, bool) {
+	return s.messageService.GetMessage(messageID)
+}
+
+// GetThread 获取话题，根消息已超出历史窗口时仍返回剩余的回复
+func (s *ChatService) GetThread(messageID string) (*models.ThreadResponse, error) {
+	parent, replies, err := s.messageService.GetThread(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID := messageID
+	if len(replies) > 0 {
+		parentID = replies[0].ReplyTo
+	}
+
+	return &models.ThreadResponse{
+		ParentID:      parentID,
+		Parent:        parent,
+		ParentEvicted: parent == nil,
+		Replies:       replies,
+	}, nil
+}
+
+// ToggleReaction 切换表情回应
+func (s *ChatService) ToggleReaction(socketID string, req *models.ReactRequest) (*models.Message, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	if !IsValidEmoji(req.Emoji) {
+		return nil, newChatError(CodeInvalidRequest, "不支持的表情")
+	}
+
+	s.userService.UpdateUserActivity(socketID)
+	return s.messageService.ToggleReaction(req.MessageID, user.ID, req.Emoji)
+}
+
 // GetOnlineUsers 获取在线用户列表
 func (s *ChatService) GetOnlineUsers() []*models.User {
 	return s.userService.GetOnlineUsers()
@@ -87,12 +701,176 @@ func (s *ChatService) GetStats() *models.ChatStats {
 	}
 }
 
+// StartTime 获取聊天服务的启动时间
+func (s *ChatService) StartTime() time.Time {
+	return s.startTime
+}
+
 // GetUser 获取用户信息
 func (s *ChatService) GetUser(socketID string) (*models.User, bool) {
 	return s.userService.GetUser(socketID)
 }
 
+// GetUserBySessionToken 根据会话令牌获取用户信息
+func (s *ChatService) GetUserBySessionToken(token string) (*models.User, bool) {
+	return s.userService.GetUserBySessionToken(token)
+}
+
+// SetAvatar 设置用户头像，支持头像编码或PNG图片
+func (s *ChatService) SetAvatar(socketID string, req *models.SetAvatarRequest) (*models.User, error) {
+	user, _, err := s.UpdateProfile(socketID, &models.UpdateProfileRequest{SetAvatarRequest: *req})
+	return user, err
+}
+
+// UpdateProfile 修改用户昵称、身份标识和头像，昵称变化时返回需要广播的系统消息
+func (s *ChatService) UpdateProfile(socketID string, req *models.UpdateProfileRequest) (*models.User, *models.Message, error) {
+	user, exists := s.userService.GetUser(socketID)
+	if !exists {
+		return nil, nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	nickname, tripcode, err := s.parseNickname(req.Nickname)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedAvatar := ""
+	if req.Avatar != "" || len(req.PNG) > 0 {
+		img, err := decodeAvatarRequest(&req.SetAvatarRequest)
+		if err != nil {
+			return nil, nil, err
+		}
+		encodedAvatar = img.String()
+	}
+
+	if req.ClearTripcode && tripcode != "" {
+		return nil, nil, newChatError(CodeInvalidRequest, "不能同时设置和清除身份口令")
+	}
+	if nickname == "" && tripcode == "" && !req.ClearTripcode && encodedAvatar == "" {
+		return nil, nil, newChatError(CodeInvalidRequest, "请提供新的昵称或头像")
+	}
+
+	if !s.profileLimiter.Allow(user.ID) {
+		return nil, nil, newChatError(CodeRateLimited, "修改资料过于频繁，请稍后再试")
+	}
+
+	updated, err := s.userService.UpdateProfile(socketID, ProfileChanges{
+		Nickname:      nickname,
+		Tripcode:      tripcode,
+		ClearTripcode: req.ClearTripcode,
+		Avatar:        encodedAvatar,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var systemMessage *models.Message
+	if nickname != "" && nickname != user.Nickname {
+		systemMessage = s.messageService.AddSystemMessage(
+			fmt.Sprintf("用户 %s 更名为 %s", displayName(user), updated.Nickname))
+	}
+
+	return updated, systemMessage, nil
+}
+
+// GetProfile 获取用户资料，用于解析历史消息中的用户ID
+func (s *ChatService) GetProfile(userID string) (*models.User, bool) {
+	return s.userService.GetProfile(userID)
+}
+
+// GetUserByID 根据用户ID获取用户信息
+func (s *ChatService) GetUserByID(userID string) (*models.User, bool) {
+	return s.userService.GetUserByID(userID)
+}
+
 // UpdateUserActivity 更新用户活动时间
 func (s *ChatService) UpdateUserActivity(socketID string) {
 	s.userService.UpdateUserActivity(socketID)
 }
+
+// parseNickname 拆分身份口令并按昵称规则校验昵称，返回规范化的昵称和公开标识
+func (s *ChatService) parseNickname(raw string) (string, string, error) {
+	nickname, tripcode, err := s.tripcodeService.Split(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	nickname, err = NormalizeNickname(nickname)
+	if err != nil {
+		return "", "", err
+	}
+	return nickname, tripcode, nil
+}
+
+// newMessage 创建由用户发送的消息草稿，发送者资料在发送时复制到消息中
+func newMessage(user *models.User, content, msgType string) *models.Message {
+	return &models.Message{
+		UserID:       user.ID,
+		UserNickname: user.Nickname,
+		UserTripcode: user.Tripcode,
+		UserAvatar:   user.Avatar,
+		UserIsBot:    user.IsBot,
+		Content:      content,
+		Type:         msgType,
+	}
+}
+
+// readIdentity 返回记录已读位置使用的持久身份，有tripcode时跨会话共享，否则跟随会话令牌，都没有时为空
+func readIdentity(user *models.User) string {
+	if user.Tripcode != "" {
+		return "tripcode:" + user.Tripcode
+	}
+	if user.SessionToken != "" {
+		return "session:" + user.SessionToken
+	}
+	return ""
+}
+
+// parseLanguage 校验代码块的语言标记，支持高亮的语言规范化为标准名称，其他语言原样保留供客户端显示
+func parseLanguage(raw string) (string, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return "", nil
+	}
+	if language := highlight.NormalizeLanguage(raw); language != "" {
+		return language, nil
+	}
+
+	invalid := func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("+#-_.", r))
+	}
+	if len(raw) > maxLanguageLength || strings.IndexFunc(raw, invalid) >= 0 {
+		return "", newChatError(CodeInvalidRequest, "代码语言标记无效")
+	}
+	return raw, nil
+}
+
+// decodeAvatarRequest 解析头像请求中的头像编码或PNG图片
+func decodeAvatarRequest(req *models.SetAvatarRequest) (*avatar.Avatar, error) {
+	var decoded *avatar.Avatar
+	var err error
+	switch {
+	case req.Avatar != "":
+		decoded, err = avatar.Decode(req.Avatar)
+	case len(req.PNG) > 0:
+		size := req.Size
+		if size == 0 {
+			size = avatar.Size
+		}
+		decoded, err = avatar.FromPNG(req.PNG, size)
+	default:
+		return nil, newChatError(CodeInvalidAvatar, "请提供头像编码或PNG图片")
+	}
+	if err != nil {
+		return nil, &ChatError{Code: CodeInvalidAvatar, Message: err.Error()}
+	}
+	return decoded, nil
+}
+
+// displayName 返回用户的显示名称，未设置昵称时使用用户ID
+func displayName(user *models.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.ID
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"testing"
+)
+
+// newTestChatService 创建使用内存存储的聊天服务
+func newTestChatService(t *testing.T) *ChatService {
+	t.Helper()
+	uploadService, err := NewUploadService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outgoing, err := NewOutgoingWebhookService(OutgoingWebhookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewChatService(NewUserService(), NewMessageService(), NewTripcodeService("test-pepper"), NewMentionService(),
+		uploadService, NewReadMarkerService(), NewRoomService(), NewAPITokenService(), NewIncomingWebhookService(),
+		outgoing, NewBotService(), ChatOptions{})
+}
+
+func TestAddUserRejectsInvalidNicknames(t *testing.T) {
+	s := newTestChatService(t)
+
+	for _, nickname := range []string{"SYSTEM", "admin#secret", "管理员", " 系统 ", "abcdefghi", "bad\x07name"} {
+		user, _, err := s.AddUser("socket-"+nickname, &models.JoinRequest{Nickname: nickname})
+		if ErrorCode(err) != CodeInvalidNickname {
+			t.Errorf("join as %q: user = %+v, err = %v, want %s", nickname, user, err, CodeInvalidNickname)
+		}
+	}
+	if count := s.userService.GetUsersCount(); count != 0 {
+		t.Errorf("online users = %d, want 0 after rejected joins", count)
+	}
+}
+
+func TestAddUserNormalizesNickname(t *testing.T) {
+	s := newTestChatService(t)
+
+	user, systemMessage, err := s.AddUser("socket-1", &models.JoinRequest{Nickname: "  alice#secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Nickname != "alice" || user.Tripcode == "" {
+		t.Errorf("user = %s/%s, want alice with a tripcode", user.Nickname, user.Tripcode)
+	}
+	if systemMessage == nil || systemMessage.Type != "system" {
+		t.Errorf("system message = %+v, want a join message", systemMessage)
+	}
+}
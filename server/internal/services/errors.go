@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 错误码，客户端据此区分错误类型，错误信息仅用于展示
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeInvalidFrame       = "INVALID_FRAME"
+	CodeUnknownEvent       = "UNKNOWN_EVENT"
+	CodeUnsupportedVersion = "UNSUPPORTED_VERSION"
+	CodeFeatureDisabled    = "FEATURE_DISABLED"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+	CodeMessageNotFound    = "MESSAGE_NOT_FOUND"
+	CodeRoomNotFound       = "ROOM_NOT_FOUND"
+	CodeImageNotFound      = "IMAGE_NOT_FOUND"
+	CodeTokenNotFound      = "TOKEN_NOT_FOUND"
+	CodeWebhookNotFound    = "WEBHOOK_NOT_FOUND"
+	CodeBotNotFound        = "BOT_NOT_FOUND"
+	CodeMessageTooLong     = "MESSAGE_TOO_LONG"
+	CodeMessageEmpty       = "MESSAGE_EMPTY"
+	CodeMessageDeleted     = "MESSAGE_DELETED"
+	CodeEditWindowExpired  = "EDIT_WINDOW_EXPIRED"
+	CodeInvalidNickname    = "INVALID_NICKNAME"
+	CodeInvalidAvatar      = "INVALID_AVATAR"
+	CodeInvalidImage       = "INVALID_IMAGE"
+	CodeImageTooLarge      = "IMAGE_TOO_LARGE"
+	CodeRoomFull           = "ROOM_FULL"
+	CodeForbidden          = "FORBIDDEN"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+// 支持的错误信息语言
+const (
+	LocaleZH = "zh-CN"
+	LocaleEN = "en"
+)
+
+// errorTextEN 各错误码的英文说明，中文错误信息带有具体细节，英文只按错误码给出概括说明
+var errorTextEN = map[string]string{
+	CodeInvalidRequest:     "The request is invalid.",
+	CodeInvalidFrame:       "The frame could not be parsed.",
+	CodeUnknownEvent:       "Unknown event type.",
+	CodeUnsupportedVersion: "No supported protocol version.",
+	CodeFeatureDisabled:    "The feature was not enabled in the handshake.",
+	CodeUserNotFound:       "User not found, please join the room again.",
+	CodeMessageNotFound:    "Message not found or no longer in history.",
+	CodeRoomNotFound:       "Room not found.",
+	CodeImageNotFound:      "Image not found, please upload it first.",
+	CodeTokenNotFound:      "API token not found.",
+	CodeWebhookNotFound:    "Webhook not found.",
+	CodeBotNotFound:        "Bot not found.",
+	CodeMessageTooLong:     "Message is too long.",
+	CodeMessageEmpty:       "Message must not be empty.",
+	CodeMessageDeleted:     "Message has been deleted.",
+	CodeEditWindowExpired:  "The message can no longer be changed.",
+	CodeInvalidNickname:    "Nickname is invalid.",
+	CodeInvalidAvatar:      "Avatar is invalid.",
+	CodeInvalidImage:       "Image is invalid or in an unsupported format.",
+	CodeImageTooLarge:      "Image is too large.",
+	CodeRoomFull:           "The room is full.",
+	CodeForbidden:          "Permission denied.",
+	CodeRateLimited:        "Too many requests, please try again later.",
+	CodeInternal:           "Internal server error.",
+}
+
+// ChatError 带错误码的业务错误
+type ChatError struct {
+	Code    string
+	Message string
+}
+
+func (e *ChatError) Error() string {
+	return e.Message
+}
+
+// newChatError 创建带错误码的错误
+func newChatError(code, format string, args ...interface{}) *ChatError {
+	return &ChatError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode 获取错误的错误码，没有错误码的错误视为无效请求
+func ErrorCode(err error) string {
+	var chatErr *ChatError
+	if errors.As(err, &chatErr) {
+		return chatErr.Code
+	}
+	return CodeInvalidRequest
+}
+
+// NormalizeLocale 规范化客户端请求的语言，不支持的语言使用中文
+func NormalizeLocale(locale string) string {
+	if len(locale) >= 2 && (locale[:2] == "en" || locale[:2] == "EN") {
+		return LocaleEN
+	}
+	return LocaleZH
+}
+
+// LocalizeError 按语言返回错误信息，中文使用原始信息
+func LocalizeError(code, message, locale string) string {
+	if locale == LocaleEN {
+		if text, ok := errorTextEN[code]; ok {
+			return text
+		}
+	}
+	return message
+}
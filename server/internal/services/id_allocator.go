@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// userIDSpace 用户ID空间大小（4位16进制）
+	userIDSpace = 0x10000
+
+	// randomAttempts 随机分配失败后转为顺序查找前的尝试次数
+	randomAttempts = 16
+
+	// DefaultIDRetention 用户离开后ID的保留时长，避免历史消息归属混淆
+	DefaultIDRetention = 30 * time.Minute
+)
+
+// IDAllocator 用户ID分配器
+type IDAllocator interface {
+	// Allocate 分配一个未被占用的用户ID
+	Allocate() (string, error)
+	// Release 释放用户ID，ID在保留期内不会被重新分配
+	Release(id string)
+	// Reclaim 重新占用保留期内的用户ID，用于断线重连后恢复身份
+	Reclaim(id string) bool
+}
+
+// HexIDAllocator 生成 User#XXXX 格式的用户ID，保证在线用户及最近离开的用户之间不重复
+type HexIDAllocator struct {
+	mu        sync.Mutex
+	live      map[int]bool
+	recent    map[int]time.Time
+	retention time.Duration
+	intn      func(n int) int
+	now       func() time.Time
+}
+
+// NewHexIDAllocator 创建默认的用户ID分配器
+func NewHexIDAllocator() *HexIDAllocator {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return NewHexIDAllocatorWith(rng.Intn, time.Now, DefaultIDRetention)
+}
+
+// NewHexIDAllocatorWith 使用指定的随机数函数、时钟和保留时长创建分配器，便于确定性测试
+func NewHexIDAllocatorWith(intn func(n int) int, now func() time.Time, retention time.Duration) *HexIDAllocator {
+	return &HexIDAllocator{
+		live:      make(map[int]bool),
+		recent:    make(map[int]time.Time),
+		retention: retention,
+		intn:      intn,
+		now:       now,
+	}
+}
+
+// Allocate 分配用户ID
+func (a *HexIDAllocator) Allocate() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pruneLocked()
+
+	for i := 0; i < randomAttempts; i++ {
+		candidate := a.intn(userIDSpace)
+		if a.availableLocked(candidate) {
+			a.live[candidate] = true
+			return formatUserID(candidate), nil
+		}
+	}
+
+	// 随机尝试失败时从随机起点顺序查找空闲ID
+	start := a.intn(userIDSpace)
+	for i := 0; i < userIDSpace; i++ {
+		candidate := (start + i) % userIDSpace
+		if a.availableLocked(candidate) {
+			a.live[candidate] = true
+			return formatUserID(candidate), nil
+		}
+	}
+
+	return "", newChatError(CodeRoomFull, "用户ID已耗尽，请稍后再试")
+}
+
+// Release 释放用户ID
+func (a *HexIDAllocator) Release(id string) {
+	value, ok := parseUserID(id)
+	if !ok {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.live[value] {
+		delete(a.live, value)
+		a.recent[value] = a.now()
+	}
+}
+
+// Reclaim 重新占用保留期内的用户ID
+func (a *HexIDAllocator) Reclaim(id string) bool {
+	value, ok := parseUserID(id)
+	if !ok {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pruneLocked()
+	if _, reserved := a.recent[value]; !reserved {
+		return false
+	}
+	delete(a.recent, value)
+	a.live[value] = true
+	return true
+}
+
+// availableLocked 判断ID是否可分配，调用方需持有锁
+func (a *HexIDAllocator) availableLocked(value int) bool {
+	if a.live[value] {
+		return false
+	}
+	_, reserved := a.recent[value]
+	return !reserved
+}
+
+// pruneLocked 清理超过保留期的ID，调用方需持有锁
+func (a *HexIDAllocator) pruneLocked() {
+	now := a.now()
+	for value, releasedAt := range a.recent {
+		if now.Sub(releasedAt) >= a.retention {
+			delete(a.recent, value)
+		}
+	}
+}
+
+// formatUserID 格式化用户ID
+func formatUserID(value int) string {
+	return fmt.Sprintf("User#%04X", value)
+}
+
+// parseUserID 解析 User#XXXX 格式的用户ID
+func parseUserID(id string) (int, bool) {
+	var value int
+	if _, err := fmt.Sscanf(id, "User#%04X", &value); err != nil {
+		return 0, false
+	}
+	if value < 0 || value >= userIDSpace || formatUserID(value) != id {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// sequence 依次返回给定值的随机数函数，用完后重复最后一个值
+func sequence(values ...int) func(n int) int {
+	i := 0
+	return func(n int) int {
+		value := values[min(i, len(values)-1)]
+		i++
+		return value % n
+	}
+}
+
+func TestHexIDAllocatorRetriesOnCollision(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	allocator := NewHexIDAllocatorWith(sequence(0xA3F2, 0xA3F2, 0xA3F2, 0x0001), clock.Now, time.Minute)
+
+	first, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != "User#A3F2" {
+		t.Errorf("first = %s, want User#A3F2", first)
+	}
+	if second != "User#0001" {
+		t.Errorf("second = %s, want User#0001", second)
+	}
+}
+
+func TestHexIDAllocatorFallsBackToSequentialScan(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	allocator := NewHexIDAllocatorWith(sequence(0x0010), clock.Now, time.Minute)
+
+	if _, err := allocator.Allocate(); err != nil {
+		t.Fatal(err)
+	}
+	// 随机数始终命中已占用的ID，应从随机起点顺序找到下一个空闲ID
+	id, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "User#0011" {
+		t.Errorf("id = %s, want User#0011", id)
+	}
+}
+
+func TestHexIDAllocatorExhaustion(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	next := 0
+	counter := func(n int) int {
+		next++
+		return (next - 1) % n
+	}
+	allocator := NewHexIDAllocatorWith(counter, clock.Now, time.Minute)
+
+	for i := 0; i < userIDSpace; i++ {
+		if _, err := allocator.Allocate(); err != nil {
+			t.Fatalf("allocate %d: %v", i, err)
+		}
+	}
+
+	_, err := allocator.Allocate()
+	if ErrorCode(err) != CodeRoomFull {
+		t.Fatalf("err = %v, want %s", err, CodeRoomFull)
+	}
+}
+
+func TestHexIDAllocatorRetainsReleasedIDs(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	allocator := NewHexIDAllocatorWith(sequence(0x0100, 0x0100, 0x0100), clock.Now, time.Minute)
+
+	id, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocator.Release(id)
+
+	// 保留期内不会重新分配
+	clock.now = clock.now.Add(30 * time.Second)
+	next, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == id {
+		t.Fatalf("released id %s reallocated within retention", id)
+	}
+
+	// 保留期过后可以重新分配
+	allocator.Release(next)
+	clock.now = clock.now.Add(2 * time.Minute)
+	again, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Errorf("again = %s, want %s after retention", again, id)
+	}
+}
+
+func TestHexIDAllocatorReclaim(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	allocator := NewHexIDAllocatorWith(sequence(0x0200, 0x0201), clock.Now, time.Minute)
+
+	id, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocator.Reclaim(id) {
+		t.Fatal("reclaimed an id that is still live")
+	}
+
+	allocator.Release(id)
+	if !allocator.Reclaim(id) {
+		t.Fatal("failed to reclaim released id within retention")
+	}
+	if allocator.Reclaim(id) {
+		t.Fatal("reclaimed the same id twice")
+	}
+
+	// 重新占用后不会再分配给其他用户
+	other, err := allocator.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == id {
+		t.Fatalf("reclaimed id %s allocated again", id)
+	}
+
+	// 保留期过后不能再重新占用
+	allocator.Release(id)
+	clock.now = clock.now.Add(2 * time.Minute)
+	if allocator.Reclaim(id) {
+		t.Error("reclaimed id after retention expired")
+	}
+	if allocator.Reclaim("Bot#0200") || allocator.Reclaim("User#zz") {
+		t.Error("reclaimed a malformed id")
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"crypto/subtle"
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/models"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIncomingWebhooks 最多同时存在的传入webhook数
+const maxIncomingWebhooks = 100
+
+// slackLinkPattern Slack消息中的 <url|label>、<url>、<@U123> 等特殊标记
+var slackLinkPattern = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+
+// slackEntities Slack要求转义的字符
+var slackEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// IncomingWebhookService 管理传入webhook，只保存密钥的哈希
+type IncomingWebhookService struct {
+	webhooks    map[string]*models.IncomingWebhook // webhook ID -> webhook
+	secrets     map[string]string                  // webhook ID -> 密钥哈希
+	webhooksMux sync.RWMutex
+}
+
+func NewIncomingWebhookService() *IncomingWebhookService {
+	return &IncomingWebhookService{
+		webhooks: make(map[string]*models.IncomingWebhook),
+		secrets:  make(map[string]string),
+	}
+}
+
+// CreateWebhook 创建传入webhook，返回webhook信息和只显示一次的密钥
+func (s *IncomingWebhookService) CreateWebhook(roomID string, req *models.CreateIncomingWebhookRequest, createdBy string) (*models.IncomingWebhook, string, error) {
+	name, err := NormalizeNickname(req.Name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	userID := "Hook#" + strings.ToUpper(id)
+	encoded := avatar.Generate(userID).String()
+	if req.Avatar != "" {
+		decoded, err := avatar.Decode(req.Avatar)
+		if err != nil {
+			return nil, "", &ChatError{Code: CodeInvalidAvatar, Message: err.Error()}
+		}
+		encoded = decoded.String()
+	}
+
+	webhook := &models.IncomingWebhook{
+		ID:        id,
+		RoomID:    roomID,
+		Name:      name,
+		UserID:    userID,
+		Avatar:    encoded,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+	}
+
+	s.webhooksMux.Lock()
+	defer s.webhooksMux.Unlock()
+
+	if len(s.webhooks) >= maxIncomingWebhooks {
+		return nil, "", newChatError(CodeInvalidRequest, "传入webhook数量已达上限")
+	}
+	if _, exists := s.webhooks[id]; exists {
+		return nil, "", newChatError(CodeInternal, "生成传入webhook失败，请重试")
+	}
+	s.webhooks[id] = webhook
+	s.secrets[id] = hashSecret(secret)
+	return webhook, secret, nil
+}
+
+// Authenticate 校验webhook密钥并记录使用时间
+func (s *IncomingWebhookService) Authenticate(id, secret string) (*models.IncomingWebhook, bool) {
+	s.webhooksMux.Lock()
+	defer s.webhooksMux.Unlock()
+
+	webhook, exists := s.webhooks[id]
+	if !exists {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(s.secrets[id]), []byte(hashSecret(secret))) != 1 {
+		return nil, false
+	}
+
+	// 复制后再修改，已返回的webhook信息保持不变
+	now := time.Now()
+	updated := *webhook
+	updated.LastUsedAt = &now
+	s.webhooks[id] = &updated
+	return &updated, true
+}
+
+// ListWebhooks 按创建时间列出传入webhook
+func (s *IncomingWebhookService) ListWebhooks() []*models.IncomingWebhook {
+	s.webhooksMux.RLock()
+	defer s.webhooksMux.RUnlock()
+
+	webhooks := make([]*models.IncomingWebhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// DeleteWebhook 删除传入webhook，之后该URL不再可用
+func (s *IncomingWebhookService) DeleteWebhook(id string) error {
+	s.webhooksMux.Lock()
+	defer s.webhooksMux.Unlock()
+
+	if _, exists := s.webhooks[id]; !exists {
+		return newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+	delete(s.webhooks, id)
+	delete(s.secrets, id)
+	return nil
+}
+
+// webhookContent 取出请求体中的消息内容，依次使用content、Slack的text和附件
+func webhookContent(payload *models.IncomingWebhookPayload) string {
+	if payload.Content != "" {
+		return payload.Content
+	}
+	if payload.Text != "" {
+		return slackToText(payload.Text)
+	}
+
+	parts := make([]string, 0, len(payload.Attachments))
+	for _, attachment := range payload.Attachments {
+		fields := []string{attachment.Pretext, attachment.Title, attachment.Text}
+		if attachment.Title == "" && attachment.Text == "" {
+			fields = []string{attachment.Pretext, attachment.Fallback}
+		}
+		for _, field := range fields {
+			if field != "" {
+				parts = append(parts, slackToText(field))
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// slackToText 将Slack格式的文本转换为纯文本：<url|label> 转换为 label (url)，并还原转义的字符
+func slackToText(text string) string {
+	text = slackLinkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := slackLinkPattern.FindStringSubmatch(match)
+		target, label := groups[1], groups[2]
+		switch {
+		case strings.HasPrefix(target, "!"):
+			// <!here>、<!channel> 等特殊提及
+			if label != "" {
+				return label
+			}
+			return "@" + strings.TrimPrefix(target, "!")
+		case strings.HasPrefix(target, "@"), strings.HasPrefix(target, "#"):
+			if label != "" {
+				return string(target[0]) + label
+			}
+			return target
+		case label != "" && label != target:
+			return label + " (" + strings.TrimPrefix(target, "mailto:") + ")"
+		default:
+			return strings.TrimPrefix(target, "mailto:")
+		}
+	})
+	return slackEntities.Replace(text)
+}
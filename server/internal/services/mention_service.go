@@ -0,0 +1,115 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// maxMentionsPerMessage 单条消息最多解析的提及数
+	maxMentionsPerMessage = 10
+
+	// maxUnreadMentions 每个用户保留的未读提及数
+	maxUnreadMentions = 100
+)
+
+// mentionPattern 匹配 @User#XXXX 或 @昵称
+var mentionPattern = regexp.MustCompile(`@(User#[0-9A-Fa-f]{4}|[^\s@]+)`)
+
+// MentionService 记录用户的未读提及
+type MentionService struct {
+	unread    map[string][]string // 用户ID -> 未读提及的消息ID
+	unreadMux sync.RWMutex
+}
+
+func NewMentionService() *MentionService {
+	return &MentionService{
+		unread: make(map[string][]string),
+	}
+}
+
+// Record 为被提及的用户记录未读提及
+func (s *MentionService) Record(userIDs []string, messageID string) {
+	s.unreadMux.Lock()
+	defer s.unreadMux.Unlock()
+
+	for _, userID := range userIDs {
+		messageIDs := append(s.unread[userID], messageID)
+		if len(messageIDs) > maxUnreadMentions {
+			messageIDs = messageIDs[len(messageIDs)-maxUnreadMentions:]
+		}
+		s.unread[userID] = messageIDs
+	}
+}
+
+// GetUnread 获取用户的未读提及
+func (s *MentionService) GetUnread(userID string) *models.MentionSummary {
+	s.unreadMux.RLock()
+	defer s.unreadMux.RUnlock()
+
+	messageIDs := make([]string, len(s.unread[userID]))
+	copy(messageIDs, s.unread[userID])
+	return &models.MentionSummary{
+		Count:      len(messageIDs),
+		MessageIDs: messageIDs,
+	}
+}
+
+// Clear 清空用户的未读提及
+func (s *MentionService) Clear(userID string) {
+	s.unreadMux.Lock()
+	defer s.unreadMux.Unlock()
+
+	delete(s.unread, userID)
+}
+
+// Prune 清理已不再保留资料的用户的未读提及，retained判断用户ID是否仍在线或在保留期内
+func (s *MentionService) Prune(retained func(userID string) bool) {
+	s.unreadMux.Lock()
+	defer s.unreadMux.Unlock()
+
+	for userID := range s.unread {
+		if !retained(userID) {
+			delete(s.unread, userID)
+		}
+	}
+}
+
+// ParseMentions 解析消息中的提及，返回去重后的用户ID，不包含发送者自己
+// users为可被提及的用户（包括保留期内离开的用户，重连后可查看），@昵称 会匹配所有同名用户
+func ParseMentions(content string, senderID string, users []*models.User) []string {
+	mentioned := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(userID string) {
+		if userID != senderID && !seen[userID] && len(mentioned) < maxMentionsPerMessage {
+			seen[userID] = true
+			mentioned = append(mentioned, userID)
+		}
+	}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		token := match[1]
+		if strings.HasPrefix(token, "User#") {
+			userID := "User#" + strings.ToUpper(strings.TrimPrefix(token, "User#"))
+			for _, user := range users {
+				if user.ID == userID {
+					add(user.ID)
+				}
+			}
+			continue
+		}
+
+		// 允许 "@amy，你好" 这类昵称后紧跟标点的写法
+		nickname := strings.TrimRightFunc(token, unicode.IsPunct)
+		for _, user := range users {
+			if user.Nickname != "" && (strings.EqualFold(user.Nickname, token) || strings.EqualFold(user.Nickname, nickname)) {
+				add(user.ID)
+			}
+		}
+	}
+
+	return mentioned
+}
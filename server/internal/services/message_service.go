@@ -1,50 +1,90 @@
 package services
 
 import (
-	"fmt"
+	"pixel-chat-server/internal/highlight"
 	"pixel-chat-server/internal/models"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// maxQuoteLength 引用内容保留的最大字符数
+const maxQuoteLength = 100
+
 type MessageService struct {
-	messages    []*models.Message
-	messagesMux sync.RWMutex
-	maxHistory  int
-	maxLength   int
+	messages      []*models.Message
+	messagesMux   sync.RWMutex
+	maxHistory    int
+	maxLength     int
+	maxCodeLength int
 }
 
 func NewMessageService() *MessageService {
+	return NewMessageServiceWith(
+		1000, // 默认最大历史消息数
+		500,  // 默认最大消息长度
+		4000, // 默认最大代码块长度
+	)
+}
+
+// NewMessageServiceWith 使用指定的历史条数和长度限制创建消息服务
+func NewMessageServiceWith(maxHistory, maxLength, maxCodeLength int) *MessageService {
 	return &MessageService{
-		messages:   make([]*models.Message, 0),
-		maxHistory: 1000, // 默认最大历史消息数
-		maxLength:  500,  // 默认最大消息长度
+		messages:      make([]*models.Message, 0),
+		maxHistory:    maxHistory,
+		maxLength:     maxLength,
+		maxCodeLength: maxCodeLength,
 	}
 }
 
-// AddMessage 添加消息
-func (s *MessageService) AddMessage(userID, userNickname, userAvatar, content, msgType string) (*models.Message, error) {
-	if len(content) > s.maxLength {
-		return nil, fmt.Errorf("消息过长")
+// validateContent 按消息类型校验内容，代码块有单独的长度限制且原样保留空白，图片消息的说明文字可以为空
+func (s *MessageService) validateContent(msgType, content string) error {
+	switch msgType {
+	case "code":
+		if len(content) > s.maxCodeLength {
+			return newChatError(CodeMessageTooLong, "代码块过长，最多%d字节", s.maxCodeLength)
+		}
+		if strings.TrimSpace(content) == "" {
+			return newChatError(CodeMessageEmpty, "代码内容不能为空")
+		}
+	case "image":
+		if len(content) > s.maxLength {
+			return newChatError(CodeMessageTooLong, "图片说明过长")
+		}
+	default:
+		if len(content) > s.maxLength {
+			return newChatError(CodeMessageTooLong, "消息过长")
+		}
+		if content == "" {
+			return newChatError(CodeMessageEmpty, "消息内容不能为空")
+		}
 	}
+	return nil
+}
 
-	if content == "" {
-		return nil, fmt.Errorf("消息内容不能为空")
+// AddMessage 添加消息，调用方填写发送者和内容，ID和时间戳由服务生成
+// 设置了ReplyTo的消息会附带被回复消息的引用，并更新所属话题的回复汇总
+func (s *MessageService) AddMessage(message *models.Message) (*models.Message, error) {
+	if err := s.validateContent(message.Type, message.Content); err != nil {
+		return nil, err
+	}
+	if message.Type == "code" {
+		message.Tokens = highlight.Tokenize(message.Language, message.Content)
 	}
 
 	s.messagesMux.Lock()
 	defer s.messagesMux.Unlock()
 
-	message := &models.Message{
-		ID:           uuid.New().String(),
-		UserID:       userID,
-		UserNickname: userNickname,
-		UserAvatar:   userAvatar,
-		Content:      content,
-		Timestamp:    time.Now(),
-		Type:         msgType,
+	message.ID = uuid.New().String()
+	message.Timestamp = time.Now()
+
+	if message.ReplyTo != "" {
+		if err := s.attachReplyLocked(message); err != nil {
+			return nil, err
+		}
 	}
 
 	s.messages = append(s.messages, message)
@@ -57,6 +97,260 @@ func (s *MessageService) AddMessage(userID, userNickname, userAvatar, content, m
 	return message, nil
 }
 
+// GetMessage 根据ID获取消息
+func (s *MessageService) GetMessage(messageID string) (*models.Message, bool) {
+	s.messagesMux.RLock()
+	defer s.messagesMux.RUnlock()
+
+	index := s.indexLocked(messageID)
+	if index < 0 {
+		return nil, false
+	}
+	return s.messages[index], true
+}
+
+// GetThread 获取话题的根消息和全部回复，根消息已超出历史窗口时parent为nil
+// 传入回复的ID时返回其所属的话题
+func (s *MessageService) GetThread(parentID string) (*models.Message, []*models.Message, error) {
+	s.messagesMux.RLock()
+	defer s.messagesMux.RUnlock()
+
+	if index := s.indexLocked(parentID); index >= 0 && s.messages[index].ReplyTo != "" {
+		parentID = s.messages[index].ReplyTo
+	}
+
+	var parent *models.Message
+	replies := make([]*models.Message, 0)
+	for _, message := range s.messages {
+		switch {
+		case message.ID == parentID:
+			parent = message
+		case message.ReplyTo == parentID:
+			replies = append(replies, message)
+		}
+	}
+
+	if parent == nil && len(replies) == 0 {
+		return nil, nil, newChatError(CodeMessageNotFound, "话题不存在或已过期")
+	}
+	return parent, replies, nil
+}
+
+// attachReplyLocked 为回复消息附加引用并更新话题汇总，调用方需持有写锁
+// 回复某条回复时，引用被回复的消息，但归入其所属的根话题
+func (s *MessageService) attachReplyLocked(message *models.Message) error {
+	quotedIndex := s.indexLocked(message.ReplyTo)
+	if quotedIndex < 0 {
+		return newChatError(CodeMessageNotFound, "回复的消息不存在或已过期")
+	}
+	quoted := s.messages[quotedIndex]
+	message.Quote = newQuote(quoted)
+
+	rootIndex := quotedIndex
+	if quoted.ReplyTo != "" {
+		rootIndex = s.indexLocked(quoted.ReplyTo)
+	}
+	if rootIndex < 0 {
+		// 根消息已超出历史窗口，仍归入原话题，只是无法更新汇总
+		message.ReplyTo = quoted.ReplyTo
+		return nil
+	}
+
+	root := *s.messages[rootIndex]
+	message.ReplyTo = root.ID
+	replyCount := 1
+	if root.Thread != nil {
+		replyCount = root.Thread.ReplyCount + 1
+	}
+	root.Thread = &models.ThreadSummary{
+		ReplyCount:      replyCount,
+		LastReplyID:     message.ID,
+		LastReplyUserID: message.UserID,
+		LastReplyAt:     message.Timestamp,
+	}
+	s.messages[rootIndex] = &root
+	return nil
+}
+
+// indexLocked 查找消息在历史中的位置，不存在时返回-1，调用方需持有锁
+func (s *MessageService) indexLocked(messageID string) int {
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Revision 消息修改的结果
+type Revision struct {
+	Message     *models.Message
+	Quote       *models.MessageQuote // 更新后的引用快照，没有回复引用该消息时为空
+	QuotedBy    []string             // 引用快照随之更新的回复ID
+	NewMentions []string             // 编辑后新提及的用户ID
+}
+
+// EditMessage 编辑消息内容，authorize用于校验编辑权限，原内容保存到编辑历史
+// mentions为按新内容解析的提及，代码消息不解析提及
+func (s *MessageService) EditMessage(messageID, editorID, content string, mentions []string, authorize func(message *models.Message) error) (*Revision, error) {
+	check := func(message *models.Message) error {
+		if err := authorize(message); err != nil {
+			return err
+		}
+		return s.validateContent(message.Type, content)
+	}
+
+	var newMentions []string
+	revision, err := s.reviseMessage(messageID, check, func(message *models.Message, now time.Time) {
+		message.Revisions = appendRevision(message.Revisions, &models.MessageRevision{
+			Content:  message.Content,
+			Action:   "edit",
+			EditedBy: editorID,
+			EditedAt: now,
+		})
+		message.Content = content
+		if message.Type == "code" {
+			message.Tokens = highlight.Tokenize(message.Language, content)
+		} else {
+			newMentions = addedMentions(message.Mentions, mentions)
+			message.Mentions = mentions
+		}
+		message.EditedAt = &now
+	})
+	if err != nil {
+		return nil, err
+	}
+	revision.NewMentions = newMentions
+	return revision, nil
+}
+
+// DeleteMessage 撤回消息，内容替换为占位，原内容保存到编辑历史
+func (s *MessageService) DeleteMessage(messageID, deleterID string, authorize func(message *models.Message) error) (*Revision, error) {
+	return s.reviseMessage(messageID, authorize, func(message *models.Message, now time.Time) {
+		message.Revisions = appendRevision(message.Revisions, &models.MessageRevision{
+			Content:  message.Content,
+			Action:   "delete",
+			EditedBy: deleterID,
+			EditedAt: now,
+		})
+		message.Content = ""
+		message.Tokens = nil
+		message.Image = nil
+		message.Mentions = nil
+		message.Reactions = nil
+		message.Deleted = true
+		message.DeletedAt = &now
+		message.DeletedBy = deleterID
+	})
+}
+
+// addedMentions 返回新提及中原来没有的用户ID
+func addedMentions(previous, current []string) []string {
+	added := make([]string, 0)
+	for _, userID := range current {
+		if !slices.Contains(previous, userID) {
+			added = append(added, userID)
+		}
+	}
+	return added
+}
+
+// GetRevisions 获取消息的编辑历史
+func (s *MessageService) GetRevisions(messageID string) (*models.Message, []*models.MessageRevision, error) {
+	s.messagesMux.RLock()
+	defer s.messagesMux.RUnlock()
+
+	index := s.indexLocked(messageID)
+	if index < 0 {
+		return nil, nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+
+	message := s.messages[index]
+	revisions := make([]*models.MessageRevision, len(message.Revisions))
+	copy(revisions, message.Revisions)
+	return message, revisions, nil
+}
+
+// reviseMessage 校验权限后修改用户消息，并同步更新引用了该消息的回复
+func (s *MessageService) reviseMessage(messageID string, authorize func(message *models.Message) error, revise func(message *models.Message, now time.Time)) (*Revision, error) {
+	s.messagesMux.Lock()
+	defer s.messagesMux.Unlock()
+
+	index := s.indexLocked(messageID)
+	if index < 0 {
+		return nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+
+	message := s.messages[index]
+	if message.Type == "system" {
+		return nil, newChatError(CodeForbidden, "系统消息不能修改")
+	}
+	if message.Deleted {
+		return nil, newChatError(CodeMessageDeleted, "消息已撤回")
+	}
+	if err := authorize(message); err != nil {
+		return nil, err
+	}
+
+	updated := *message
+	revise(&updated, time.Now())
+	s.messages[index] = &updated
+
+	revision := &Revision{Message: &updated, QuotedBy: s.refreshQuotesLocked(&updated)}
+	if len(revision.QuotedBy) > 0 {
+		revision.Quote = newQuote(&updated)
+	}
+	return revision, nil
+}
+
+// refreshQuotesLocked 更新引用了该消息的回复中的引用快照，返回更新的回复ID，调用方需持有写锁
+func (s *MessageService) refreshQuotesLocked(quoted *models.Message) []string {
+	replyIDs := make([]string, 0)
+	for i, message := range s.messages {
+		if message.Quote == nil || message.Quote.ID != quoted.ID {
+			continue
+		}
+		updated := *message
+		updated.Quote = newQuote(quoted)
+		s.messages[i] = &updated
+		replyIDs = append(replyIDs, updated.ID)
+	}
+	return replyIDs
+}
+
+// ToggleReaction 切换用户对消息的表情回应，系统消息和已撤回的消息不能回应
+func (s *MessageService) ToggleReaction(messageID, userID, emoji string) (*models.Message, error) {
+	return s.updateMessage(messageID, func(message *models.Message) error {
+		if message.Type == "system" {
+			return newChatError(CodeForbidden, "系统消息不能回应")
+		}
+		if message.Deleted {
+			return newChatError(CodeMessageDeleted, "消息已撤回")
+		}
+		message.Reactions = toggleReaction(message.Reactions, emoji, userID)
+		return nil
+	})
+}
+
+// updateMessage 以副本方式修改消息，避免与正在序列化旧对象的goroutine产生竞争
+// update中对切换类字段需要创建新的切片，不能修改原切片
+func (s *MessageService) updateMessage(messageID string, update func(message *models.Message) error) (*models.Message, error) {
+	s.messagesMux.Lock()
+	defer s.messagesMux.Unlock()
+
+	index := s.indexLocked(messageID)
+	if index < 0 {
+		return nil, newChatError(CodeMessageNotFound, "消息不存在或已过期")
+	}
+
+	updated := *s.messages[index]
+	if err := update(&updated); err != nil {
+		return nil, err
+	}
+	s.messages[index] = &updated
+	return &updated, nil
+}
+
 // GetRecentMessages 获取最近的消息
 func (s *MessageService) GetRecentMessages(limit int) []*models.Message {
 	s.messagesMux.RLock()
@@ -77,6 +371,43 @@ func (s *MessageService) GetRecentMessages(limit int) []*models.Message {
 	return messages
 }
 
+// SetMessageSeq 记录广播消息时分配的房间事件序号
+func (s *MessageService) SetMessageSeq(messageID string, seq uint64) {
+	s.updateMessage(messageID, func(message *models.Message) error {
+		message.Seq = seq
+		return nil
+	})
+}
+
+// GetLatestMessage 获取最新的消息
+func (s *MessageService) GetLatestMessage() (*models.Message, bool) {
+	s.messagesMux.RLock()
+	defer s.messagesMux.RUnlock()
+
+	if len(s.messages) == 0 {
+		return nil, false
+	}
+	return s.messages[len(s.messages)-1], true
+}
+
+// CountUnread 统计指定时间之后其他用户发送的消息数，不包括系统消息和已撤回的消息
+func (s *MessageService) CountUnread(since time.Time, userID string) int {
+	s.messagesMux.RLock()
+	defer s.messagesMux.RUnlock()
+
+	count := 0
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		message := s.messages[i]
+		if !message.Timestamp.After(since) {
+			break
+		}
+		if message.Type != "system" && !message.Deleted && message.UserID != userID {
+			count++
+		}
+	}
+	return count
+}
+
 // GetAllMessages 获取所有消息
 func (s *MessageService) GetAllMessages() []*models.Message {
 	s.messagesMux.RLock()
@@ -119,3 +450,26 @@ func (s *MessageService) AddSystemMessage(content string) *models.Message {
 
 	return message
 }
+
+// appendRevision 追加编辑历史，返回新的切片，不修改原切片
+func appendRevision(revisions []*models.MessageRevision, revision *models.MessageRevision) []*models.MessageRevision {
+	result := make([]*models.MessageRevision, len(revisions), len(revisions)+1)
+	copy(result, revisions)
+	return append(result, revision)
+}
+
+// newQuote 创建消息引用，内容过长时截断
+func newQuote(message *models.Message) *models.MessageQuote {
+	content := []rune(message.Content)
+	if len(content) > maxQuoteLength {
+		content = append(content[:maxQuoteLength], '…')
+	}
+	return &models.MessageQuote{
+		ID:           message.ID,
+		UserID:       message.UserID,
+		UserNickname: message.UserNickname,
+		Content:      string(content),
+		Type:         message.Type,
+		Deleted:      message.Deleted,
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"testing"
+)
+
+// addTestMessage 以指定用户添加一条文本消息
+func addTestMessage(t *testing.T, s *MessageService, userID, content string) *models.Message {
+	t.Helper()
+	message, err := s.AddMessage(&models.Message{UserID: userID, UserNickname: userID, Content: content, Type: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestToggleReaction(t *testing.T) {
+	s := NewMessageService()
+	message := addTestMessage(t, s, "User#0001", "hello")
+
+	updated, err := s.ToggleReaction(message.ID, "User#0002", "smile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Reactions) != 1 || updated.Reactions[0].Count != 1 {
+		t.Fatalf("reactions = %+v, want one smile", updated.Reactions)
+	}
+
+	updated, err = s.ToggleReaction(message.ID, "User#0002", "smile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Reactions) != 0 {
+		t.Errorf("reactions = %+v, want none after toggling twice", updated.Reactions)
+	}
+}
+
+func TestToggleReactionRejectsDeletedMessage(t *testing.T) {
+	s := NewMessageService()
+	message := addTestMessage(t, s, "User#0001", "hello")
+	if _, err := s.ToggleReaction(message.ID, "User#0002", "smile"); err != nil {
+		t.Fatal(err)
+	}
+
+	allow := func(*models.Message) error { return nil }
+	if _, err := s.DeleteMessage(message.ID, "User#0001", allow); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.ToggleReaction(message.ID, "User#0002", "smile")
+	if ErrorCode(err) != CodeMessageDeleted {
+		t.Fatalf("err = %v, want %s", err, CodeMessageDeleted)
+	}
+	if deleted, _ := s.GetMessage(message.ID); len(deleted.Reactions) != 0 {
+		t.Errorf("deleted message reactions = %+v, want none", deleted.Reactions)
+	}
+}
+
+func TestToggleReactionRejectsSystemMessage(t *testing.T) {
+	s := NewMessageService()
+	message := s.AddSystemMessage("User#0001 加入了聊天室")
+
+	_, err := s.ToggleReaction(message.ID, "User#0002", "smile")
+	if ErrorCode(err) != CodeForbidden {
+		t.Fatalf("err = %v, want %s", err, CodeForbidden)
+	}
+	if system, _ := s.GetMessage(message.ID); len(system.Reactions) != 0 {
+		t.Errorf("system message reactions = %+v, want none", system.Reactions)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNicknameLength 昵称最大字符数
+const MaxNicknameLength = 8
+
+// reservedNicknames 保留昵称，防止冒充系统消息
+var reservedNicknames = []string{"system", "admin", "管理员", "系统"}
+
+// NormalizeNickname 按昵称规则校验并规范化昵称，空昵称表示使用用户ID显示
+func NormalizeNickname(nickname string) (string, error) {
+	nickname = strings.TrimSpace(nickname)
+	if nickname == "" {
+		return "", nil
+	}
+
+	if !utf8.ValidString(nickname) {
+		return "", newChatError(CodeInvalidNickname, "昵称包含无效字符")
+	}
+	if utf8.RuneCountInString(nickname) > MaxNicknameLength {
+		return "", newChatError(CodeInvalidNickname, "昵称最多%d个字符", MaxNicknameLength)
+	}
+	for _, r := range nickname {
+		if unicode.IsControl(r) {
+			return "", newChatError(CodeInvalidNickname, "昵称包含无效字符")
+		}
+	}
+	for _, reserved := range reservedNicknames {
+		if strings.EqualFold(nickname, reserved) {
+			return "", newChatError(CodeInvalidNickname, "该昵称不可使用")
+		}
+	}
+
+	return nickname, nil
+}
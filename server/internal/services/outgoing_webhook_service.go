@@ -0,0 +1,675 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"pixel-chat-server/internal/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 传出webhook可订阅的事件
+const (
+	WebhookEventMessage = "message"
+	WebhookEventJoin    = "join"
+	WebhookEventLeave   = "leave"
+	WebhookEventReport  = "report"
+
+	// webhookEventPing 测试投递，发送给指定webhook，不需要订阅
+	webhookEventPing = "ping"
+)
+
+const (
+	// WebhookSignatureHeader 请求体的HMAC-SHA256签名，格式为 sha256=<hex>
+	WebhookSignatureHeader = "X-PixelChat-Signature"
+
+	// maxOutgoingWebhooks 最多同时存在的传出webhook数
+	maxOutgoingWebhooks = 20
+
+	// maxQueuedDeliveries 待投递队列的最大长度，超出时丢弃新事件
+	maxQueuedDeliveries = 1000
+
+	// maxDeliveryAttempts 每次投递的最大尝试次数，之后放弃
+	maxDeliveryAttempts = 8
+
+	// defaultRetryBaseDelay 首次重试的默认等待时间，之后每次翻倍，不超过retryMaxDelay
+	defaultRetryBaseDelay = 10 * time.Second
+	retryMaxDelay         = time.Hour
+
+	// defaultDisableAfterFailures 连续失败的尝试次数达到该值时自动停用webhook
+	defaultDisableAfterFailures = 15
+
+	// maxDeliveryLogs 每个webhook保留的投递记录数
+	maxDeliveryLogs = 50
+
+	// maxConcurrentDeliveries 同时进行的投递数
+	maxConcurrentDeliveries = 8
+
+	// deliveryTimeout 单次投递的超时时间
+	deliveryTimeout = 10 * time.Second
+
+	// defaultDeliveryPollInterval 检查到期重试的默认间隔
+	defaultDeliveryPollInterval = time.Second
+)
+
+// webhookEvents 可订阅的事件
+var webhookEvents = map[string]bool{
+	WebhookEventMessage: true,
+	WebhookEventJoin:    true,
+	WebhookEventLeave:   true,
+	WebhookEventReport:  true,
+}
+
+// storedWebhook 持久化的传出webhook，签名密钥只保存在服务端
+type storedWebhook struct {
+	models.OutgoingWebhook
+	Secret string `json:"secret"`
+}
+
+// queuedDelivery 待投递的事件，请求体在入队时生成，重试时保持不变
+type queuedDelivery struct {
+	ID          string          `json:"id"`
+	WebhookID   string          `json:"webhook_id"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+
+	inFlight bool
+}
+
+// webhookStore 持久化到文件的全部状态
+type webhookStore struct {
+	Webhooks []*storedWebhook                     `json:"webhooks"`
+	Queue    []*queuedDelivery                    `json:"queue"`
+	Logs     map[string][]*models.WebhookDelivery `json:"logs"`
+}
+
+// OutgoingWebhookOptions 传出webhook服务配置
+type OutgoingWebhookOptions struct {
+	StorePath string       // 持久化文件路径，重启后恢复webhook、待投递队列和投递记录
+	Client    *http.Client // 为空时使用带超时的默认客户端
+
+	// 以下字段为零值时使用默认值，主要用于测试
+	RetryBaseDelay       time.Duration    // 首次重试的等待时间，默认10秒
+	PollInterval         time.Duration    // 检查到期重试的间隔，默认1秒
+	DisableAfterFailures int              // 连续失败多少次后自动停用，默认15
+	Now                  func() time.Time // 时钟，默认time.Now
+}
+
+// OutgoingWebhookService 将聊天室事件推送给已注册的URL
+// 事件先进入持久化队列，由Run按指数退避投递，连续失败过多的webhook会被自动停用
+type OutgoingWebhookService struct {
+	mu       sync.Mutex
+	webhooks map[string]*storedWebhook
+	queue    []*queuedDelivery
+	logs     map[string][]*models.WebhookDelivery
+	dirty    bool
+
+	storePath string
+	saveMux   sync.Mutex // 保证写文件的顺序
+	client    *http.Client
+	wake      chan struct{}
+	slots     chan struct{}
+	now       func() time.Time
+
+	retryBaseDelay       time.Duration
+	pollInterval         time.Duration
+	disableAfterFailures int
+}
+
+// NewOutgoingWebhookService 创建传出webhook服务并从文件恢复状态
+func NewOutgoingWebhookService(options OutgoingWebhookOptions) (*OutgoingWebhookService, error) {
+	s := &OutgoingWebhookService{
+		webhooks:  make(map[string]*storedWebhook),
+		logs:      make(map[string][]*models.WebhookDelivery),
+		storePath: options.StorePath,
+		client:    options.Client,
+		wake:      make(chan struct{}, 1),
+		slots:     make(chan struct{}, maxConcurrentDeliveries),
+		now:       options.Now,
+
+		retryBaseDelay:       options.RetryBaseDelay,
+		pollInterval:         options.PollInterval,
+		disableAfterFailures: options.DisableAfterFailures,
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: deliveryTimeout}
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.retryBaseDelay <= 0 {
+		s.retryBaseDelay = defaultRetryBaseDelay
+	}
+	if s.pollInterval <= 0 {
+		s.pollInterval = defaultDeliveryPollInterval
+	}
+	if s.disableAfterFailures <= 0 {
+		s.disableAfterFailures = defaultDisableAfterFailures
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run 持续投递到期的事件，需要在单独的goroutine中运行
+func (s *OutgoingWebhookService) Run() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.deliverDue()
+		s.saveIfDirty()
+	}
+}
+
+// Dispatch 将事件加入所有订阅了该事件的webhook的投递队列，不会阻塞
+func (s *OutgoingWebhookService) Dispatch(event string, data interface{}) {
+	s.mu.Lock()
+	var targets []string
+	for id, webhook := range s.webhooks {
+		if webhook.Active && subscribes(&webhook.OutgoingWebhook, event) {
+			targets = append(targets, id)
+		}
+	}
+	s.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	for _, webhookID := range targets {
+		if err := s.enqueue(webhookID, event, data); err != nil {
+			log.Printf("传出webhook事件入队失败: %v", err)
+		}
+	}
+	s.signal()
+}
+
+// Ping 向指定webhook发送一次测试事件，已停用的webhook不会投递，需要先启用
+func (s *OutgoingWebhookService) Ping(id string) error {
+	s.mu.Lock()
+	webhook, exists := s.webhooks[id]
+	s.mu.Unlock()
+	if !exists {
+		return newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+	if !webhook.Active {
+		return newChatError(CodeInvalidRequest, "webhook已停用，请先启用")
+	}
+
+	if err := s.enqueue(id, webhookEventPing, map[string]string{"webhook_id": id}); err != nil {
+		return err
+	}
+	s.signal()
+	return nil
+}
+
+// CreateWebhook 注册传出webhook，返回webhook信息和只显示一次的签名密钥
+func (s *OutgoingWebhookService) CreateWebhook(req *models.CreateOutgoingWebhookRequest, createdBy string) (*models.OutgoingWebhook, string, error) {
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, "", err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook := &storedWebhook{
+		OutgoingWebhook: models.OutgoingWebhook{
+			ID:        id,
+			URL:       req.URL,
+			Events:    events,
+			Active:    true,
+			CreatedAt: s.now(),
+			CreatedBy: createdBy,
+		},
+		Secret: secret,
+	}
+
+	s.mu.Lock()
+	if len(s.webhooks) >= maxOutgoingWebhooks {
+		s.mu.Unlock()
+		return nil, "", newChatError(CodeInvalidRequest, "传出webhook数量已达上限")
+	}
+	if _, exists := s.webhooks[id]; exists {
+		s.mu.Unlock()
+		return nil, "", newChatError(CodeInternal, "生成传出webhook失败，请重试")
+	}
+	s.webhooks[id] = webhook
+	s.dirty = true
+	info := webhook.OutgoingWebhook
+	s.mu.Unlock()
+
+	s.saveIfDirty()
+	return &info, secret, nil
+}
+
+// UpdateWebhook 修改传出webhook，重新启用时清零连续失败次数
+func (s *OutgoingWebhookService) UpdateWebhook(id string, req *models.UpdateOutgoingWebhookRequest) (*models.OutgoingWebhook, error) {
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			return nil, err
+		}
+	}
+	var events []string
+	if req.Events != nil {
+		var err error
+		if events, err = normalizeWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	webhook, exists := s.webhooks[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+
+	// 复制后再修改，已返回的webhook信息保持不变
+	updated := *webhook
+	if req.URL != nil {
+		updated.URL = *req.URL
+	}
+	if events != nil {
+		updated.Events = events
+	}
+	if req.Active != nil {
+		if *req.Active && !updated.Active {
+			updated.ConsecutiveFailures = 0
+			updated.DisabledReason = ""
+		}
+		updated.Active = *req.Active
+		if !updated.Active {
+			s.dropQueuedLocked(id)
+		}
+	}
+	s.webhooks[id] = &updated
+	s.dirty = true
+	info := updated.OutgoingWebhook
+	s.mu.Unlock()
+
+	s.saveIfDirty()
+	return &info, nil
+}
+
+// DeleteWebhook 删除传出webhook及其待投递事件和投递记录
+func (s *OutgoingWebhookService) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	if _, exists := s.webhooks[id]; !exists {
+		s.mu.Unlock()
+		return newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+	delete(s.webhooks, id)
+	delete(s.logs, id)
+	s.dropQueuedLocked(id)
+	s.dirty = true
+	s.mu.Unlock()
+
+	s.saveIfDirty()
+	return nil
+}
+
+// ListWebhooks 按创建时间列出传出webhook，不包含签名密钥
+func (s *OutgoingWebhookService) ListWebhooks() []*models.OutgoingWebhook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := make([]*models.OutgoingWebhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		info := webhook.OutgoingWebhook
+		webhooks = append(webhooks, &info)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// Deliveries 返回webhook最近的投递记录，最新的在前
+func (s *OutgoingWebhookService) Deliveries(id string) ([]*models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.webhooks[id]; !exists {
+		return nil, newChatError(CodeWebhookNotFound, "webhook不存在")
+	}
+	logs := s.logs[id]
+	deliveries := make([]*models.WebhookDelivery, 0, len(logs))
+	for i := len(logs) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, logs[i])
+	}
+	return deliveries, nil
+}
+
+// enqueue 生成请求体并加入投递队列
+func (s *OutgoingWebhookService) enqueue(webhookID, event string, data interface{}) error {
+	deliveryID := uuid.New().String()
+	payload, err := json.Marshal(models.WebhookPayload{
+		ID:        deliveryID,
+		Event:     event,
+		Timestamp: s.now(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) >= maxQueuedDeliveries {
+		return fmt.Errorf("投递队列已满，丢弃%s事件", event)
+	}
+	s.queue = append(s.queue, &queuedDelivery{
+		ID:          deliveryID,
+		WebhookID:   webhookID,
+		Event:       event,
+		Payload:     payload,
+		NextAttempt: s.now(),
+	})
+	s.dirty = true
+	return nil
+}
+
+// deliverDue 投递所有到期的事件，同时进行的投递数不超过maxConcurrentDeliveries
+func (s *OutgoingWebhookService) deliverDue() {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, delivery := range s.queue {
+		if delivery.inFlight || delivery.NextAttempt.After(now) {
+			continue
+		}
+		webhook, exists := s.webhooks[delivery.WebhookID]
+		if !exists || !webhook.Active {
+			continue
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			return
+		}
+		delivery.inFlight = true
+		go s.deliver(delivery, webhook.URL, webhook.Secret)
+	}
+}
+
+// deliver 发送一次投递并记录结果
+func (s *OutgoingWebhookService) deliver(delivery *queuedDelivery, target, secret string) {
+	defer func() { <-s.slots }()
+
+	start := s.now()
+	statusCode, err := s.post(target, secret, delivery)
+	s.complete(delivery, &models.WebhookDelivery{
+		DeliveryID: delivery.ID,
+		Event:      delivery.Event,
+		Attempt:    delivery.Attempts + 1,
+		Success:    err == nil,
+		StatusCode: statusCode,
+		Error:      errorText(err),
+		DurationMS: s.now().Sub(start).Milliseconds(),
+		Timestamp:  start,
+	})
+	s.signal()
+}
+
+// post 发送带签名的请求，非2xx响应视为失败
+func (s *OutgoingWebhookService) post(target, secret string, delivery *queuedDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PixelChat-Webhook/1.0")
+	req.Header.Set("X-PixelChat-Event", delivery.Event)
+	req.Header.Set("X-PixelChat-Delivery", delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("接收方返回%d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// complete 记录投递结果，失败时按指数退避安排重试，连续失败过多时停用webhook
+func (s *OutgoingWebhookService) complete(delivery *queuedDelivery, result *models.WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery.inFlight = false
+	delivery.Attempts++
+	s.dirty = true
+
+	webhook, exists := s.webhooks[delivery.WebhookID]
+	if !exists {
+		s.removeQueuedLocked(delivery)
+		return
+	}
+	logs := append(s.logs[webhook.ID], result)
+	if len(logs) > maxDeliveryLogs {
+		logs = logs[len(logs)-maxDeliveryLogs:]
+	}
+	s.logs[webhook.ID] = logs
+
+	updated := *webhook
+	if result.Success {
+		updated.ConsecutiveFailures = 0
+		updated.LastDeliveryAt = &result.Timestamp
+		s.webhooks[webhook.ID] = &updated
+		s.removeQueuedLocked(delivery)
+		return
+	}
+
+	updated.ConsecutiveFailures++
+	if updated.ConsecutiveFailures >= s.disableAfterFailures {
+		updated.Active = false
+		updated.DisabledReason = fmt.Sprintf("连续%d次投递失败，最后一次: %s", updated.ConsecutiveFailures, result.Error)
+		s.webhooks[webhook.ID] = &updated
+		s.dropQueuedLocked(webhook.ID)
+		log.Printf("传出webhook %s 已自动停用: %s", webhook.ID, updated.DisabledReason)
+		return
+	}
+	s.webhooks[webhook.ID] = &updated
+
+	// 投递期间webhook被停用时不再重试
+	if !updated.Active || delivery.Attempts >= maxDeliveryAttempts {
+		s.removeQueuedLocked(delivery)
+		return
+	}
+	delivery.NextAttempt = s.now().Add(retryDelay(s.retryBaseDelay, delivery.Attempts))
+}
+
+// removeQueuedLocked 从队列中移除投递，调用方需持有锁
+func (s *OutgoingWebhookService) removeQueuedLocked(delivery *queuedDelivery) {
+	for i, queued := range s.queue {
+		if queued == delivery {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// dropQueuedLocked 丢弃webhook尚未开始的投递，调用方需持有锁
+func (s *OutgoingWebhookService) dropQueuedLocked(webhookID string) {
+	queue := s.queue[:0]
+	for _, delivery := range s.queue {
+		if delivery.WebhookID != webhookID || delivery.inFlight {
+			queue = append(queue, delivery)
+		}
+	}
+	for i := len(queue); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = queue
+}
+
+// signal 唤醒投递循环
+func (s *OutgoingWebhookService) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// load 从文件恢复状态，文件不存在时从空状态开始
+func (s *OutgoingWebhookService) load() error {
+	if s.storePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.storePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取webhook数据失败: %v", err)
+	}
+
+	var store webhookStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return fmt.Errorf("解析webhook数据失败: %v", err)
+	}
+	for _, webhook := range store.Webhooks {
+		s.webhooks[webhook.ID] = webhook
+	}
+	s.queue = store.Queue
+	if store.Logs != nil {
+		s.logs = store.Logs
+	}
+	return nil
+}
+
+// saveIfDirty 状态有变化时写入文件
+func (s *OutgoingWebhookService) saveIfDirty() {
+	s.saveMux.Lock()
+	defer s.saveMux.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty || s.storePath == "" {
+		s.mu.Unlock()
+		return
+	}
+	store := webhookStore{
+		Webhooks: make([]*storedWebhook, 0, len(s.webhooks)),
+		Queue:    s.queue,
+		Logs:     s.logs,
+	}
+	for _, webhook := range s.webhooks {
+		store.Webhooks = append(store.Webhooks, webhook)
+	}
+	data, err := json.Marshal(store)
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(s.storePath, data)
+	}
+	if err != nil {
+		log.Printf("保存webhook数据失败: %v", err)
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+}
+
+// SignWebhookPayload 计算请求体的签名，接收方用相同的密钥计算后比较 X-PixelChat-Signature
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay 第attempts次失败后的等待时间，从base开始每次翻倍
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// subscribes webhook是否订阅了事件
+func subscribes(webhook *models.OutgoingWebhook, event string) bool {
+	for _, subscribed := range webhook.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebhookEvents 校验并去重订阅的事件
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, newChatError(CodeInvalidRequest, "请指定订阅的事件")
+	}
+
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, newChatError(CodeInvalidRequest, "不支持的事件: %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+// validateWebhookURL 校验webhook地址为http或https的绝对地址
+func validateWebhookURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return newChatError(CodeInvalidRequest, "webhook地址必须是http或https地址")
+	}
+	return nil
+}
+
+// errorText 返回错误信息，没有错误时为空
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"pixel-chat-server/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver 记录收到的请求，按statuses依次返回状态码，用完后重复最后一个
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*receivedWebhook
+}
+
+// receivedWebhook 接收方收到的一次请求
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, &receivedWebhook{header: req.Header.Clone(), body: body})
+	status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+// received 返回目前收到的请求
+func (r *webhookReceiver) received() []*receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*receivedWebhook(nil), r.requests...)
+}
+
+// newTestWebhook 创建订阅消息事件并指向接收方的webhook
+func newTestWebhook(t *testing.T, s *OutgoingWebhookService, receiver *httptest.Server) (*models.OutgoingWebhook, string) {
+	t.Helper()
+	webhook, secret, err := s.CreateWebhook(&models.CreateOutgoingWebhookRequest{
+		URL:    receiver.URL,
+		Events: []string{WebhookEventMessage},
+	}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webhook, secret
+}
+
+// deliverAll 投递所有到期的事件并等待投递结束
+func deliverAll(t *testing.T, s *OutgoingWebhookService) {
+	t.Helper()
+	s.deliverDue()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		pending := false
+		for _, delivery := range s.queue {
+			pending = pending || delivery.inFlight
+		}
+		s.mu.Unlock()
+		if !pending {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for deliveries")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// queued 返回队列的快照
+func queued(s *OutgoingWebhookService) []queuedDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := make([]queuedDelivery, 0, len(s.queue))
+	for _, delivery := range s.queue {
+		queue = append(queue, *delivery)
+	}
+	return queue
+}
+
+func TestOutgoingWebhookSignsPayload(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s, err := NewOutgoingWebhookService(OutgoingWebhookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, secret := newTestWebhook(t, s, server)
+
+	s.Dispatch(WebhookEventJoin, map[string]string{"user_id": "User#0001"})
+	s.Dispatch(WebhookEventMessage, map[string]string{"content": "hello"})
+	deliverAll(t, s)
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1 for the subscribed event", len(requests))
+	}
+	request := requests[0]
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(request.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := request.header.Get(WebhookSignatureHeader); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := SignWebhookPayload("other-secret", request.body); got == want {
+		t.Error("signature does not depend on the secret")
+	}
+
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != WebhookEventMessage || request.header.Get("X-PixelChat-Event") != WebhookEventMessage {
+		t.Errorf("event = %q/%q, want message", payload.Event, request.header.Get("X-PixelChat-Event"))
+	}
+	if payload.ID == "" || request.header.Get("X-PixelChat-Delivery") != payload.ID {
+		t.Errorf("delivery header = %q, want payload id %q", request.header.Get("X-PixelChat-Delivery"), payload.ID)
+	}
+
+	deliveries, err := s.Deliveries(webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || !deliveries[0].Success || deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("deliveries = %+v, want one successful delivery", deliveries)
+	}
+	if len(queued(s)) != 0 {
+		t.Error("delivered event is still queued")
+	}
+}
+
+func TestOutgoingWebhookRetriesAfterServerError(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError, http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s, err := NewOutgoingWebhookService(OutgoingWebhookOptions{RetryBaseDelay: time.Minute, Now: clock.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, _ := newTestWebhook(t, s, server)
+
+	s.Dispatch(WebhookEventMessage, map[string]string{"content": "hello"})
+	deliverAll(t, s)
+
+	queue := queued(s)
+	if len(queue) != 1 || queue[0].Attempts != 1 {
+		t.Fatalf("queue = %+v, want one delivery after the first attempt", queue)
+	}
+	if want := clock.now.Add(time.Minute); !queue[0].NextAttempt.Equal(want) {
+		t.Errorf("next attempt = %v, want %v", queue[0].NextAttempt, want)
+	}
+
+	// 未到重试时间时不会投递
+	clock.now = clock.now.Add(30 * time.Second)
+	deliverAll(t, s)
+	if got := len(receiver.received()); got != 1 {
+		t.Fatalf("received %d requests before the retry delay, want 1", got)
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	deliverAll(t, s)
+
+	requests := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("received %d requests, want 2", len(requests))
+	}
+	if requests[0].header.Get("X-PixelChat-Delivery") != requests[1].header.Get("X-PixelChat-Delivery") {
+		t.Error("retry changed the delivery id")
+	}
+	if len(queued(s)) != 0 {
+		t.Error("event is still queued after a successful retry")
+	}
+
+	webhooks := s.ListWebhooks()
+	if webhooks[0].ConsecutiveFailures != 0 || webhooks[0].LastDeliveryAt == nil {
+		t.Errorf("webhook = %+v, want failures reset after success", webhooks[0])
+	}
+	deliveries, _ := s.Deliveries(webhook.ID)
+	if len(deliveries) != 2 || deliveries[1].Success || deliveries[1].StatusCode != http.StatusInternalServerError {
+		t.Errorf("deliveries = %+v, want a failed attempt followed by a success", deliveries)
+	}
+}
+
+func TestOutgoingWebhookDisablesAfterFailures(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s, err := NewOutgoingWebhookService(OutgoingWebhookOptions{DisableAfterFailures: 3, Now: clock.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, _ := newTestWebhook(t, s, server)
+
+	s.Dispatch(WebhookEventMessage, map[string]string{"content": "hello"})
+	for i := 0; i < 5; i++ {
+		deliverAll(t, s)
+		clock.now = clock.now.Add(retryMaxDelay)
+	}
+
+	if got := len(receiver.received()); got != 3 {
+		t.Errorf("received %d requests, want 3 before disabling", got)
+	}
+	webhooks := s.ListWebhooks()
+	if webhooks[0].Active || webhooks[0].DisabledReason == "" || webhooks[0].ConsecutiveFailures != 3 {
+		t.Fatalf("webhook = %+v, want disabled after 3 failures", webhooks[0])
+	}
+	if len(queued(s)) != 0 {
+		t.Error("disabled webhook still has queued deliveries")
+	}
+
+	// 停用后不再接收事件，测试事件也会被拒绝
+	s.Dispatch(WebhookEventMessage, map[string]string{"content": "again"})
+	if len(queued(s)) != 0 {
+		t.Error("disabled webhook received a new event")
+	}
+	if err := s.Ping(webhook.ID); ErrorCode(err) != CodeInvalidRequest {
+		t.Errorf("ping err = %v, want %s", err, CodeInvalidRequest)
+	}
+
+	// 重新启用后清零失败次数，可以再次测试
+	active := true
+	if _, err := s.UpdateWebhook(webhook.ID, &models.UpdateOutgoingWebhookRequest{Active: &active}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(webhook.ID); err != nil {
+		t.Errorf("ping after enabling: %v", err)
+	}
+}
+
+func TestOutgoingWebhookReloadsQueue(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	storePath := filepath.Join(t.TempDir(), "webhooks.json")
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	options := OutgoingWebhookOptions{StorePath: storePath, Now: clock.Now}
+
+	s, err := NewOutgoingWebhookService(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, secret := newTestWebhook(t, s, server)
+
+	s.Dispatch(WebhookEventMessage, map[string]string{"content": "hello"})
+	deliverAll(t, s)
+	s.saveIfDirty()
+
+	// 重启后从文件恢复webhook、待重试的事件和投递记录
+	restored, err := NewOutgoingWebhookService(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := queued(restored)
+	if len(queue) != 1 || queue[0].WebhookID != webhook.ID || queue[0].Attempts != 1 {
+		t.Fatalf("restored queue = %+v, want the pending retry", queue)
+	}
+	deliveries, err := restored.Deliveries(webhook.ID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("restored deliveries = %+v, %v, want the failed attempt", deliveries, err)
+	}
+
+	clock.now = clock.now.Add(retryMaxDelay)
+	deliverAll(t, restored)
+
+	requests := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("received %d requests, want 2", len(requests))
+	}
+	if string(requests[0].body) != string(requests[1].body) {
+		t.Error("restored delivery changed the payload")
+	}
+	if got := requests[1].header.Get(WebhookSignatureHeader); got != SignWebhookPayload(secret, requests[1].body) {
+		t.Error("restored delivery is not signed with the original secret")
+	}
+	if len(queued(restored)) != 0 {
+		t.Error("event is still queued after delivery")
+	}
+}
+
+func TestOutgoingWebhookPing(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s, err := NewOutgoingWebhookService(OutgoingWebhookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, _ := newTestWebhook(t, s, server)
+
+	// 不存在的webhook和已停用的webhook都不会投递
+	if err := s.Ping("missing"); ErrorCode(err) != CodeWebhookNotFound {
+		t.Errorf("ping missing: err = %v, want %s", err, CodeWebhookNotFound)
+	}
+	inactive := false
+	if _, err := s.UpdateWebhook(webhook.ID, &models.UpdateOutgoingWebhookRequest{Active: &inactive}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(webhook.ID); ErrorCode(err) != CodeInvalidRequest {
+		t.Errorf("ping disabled: err = %v, want %s", err, CodeInvalidRequest)
+	}
+	deliverAll(t, s)
+	if got := len(receiver.received()); got != 0 {
+		t.Fatalf("received %d requests for rejected pings, want 0", got)
+	}
+	if len(queued(s)) != 0 {
+		t.Error("rejected ping was queued")
+	}
+
+	// 启用后测试事件发给该webhook，即使它没有订阅ping
+	active := true
+	if _, err := s.UpdateWebhook(webhook.ID, &models.UpdateOutgoingWebhookRequest{Active: &active}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	deliverAll(t, s)
+
+	requests := receiver.received()
+	if len(requests) != 1 || requests[0].header.Get("X-PixelChat-Event") != webhookEventPing {
+		t.Fatalf("requests = %d, want one ping", len(requests))
+	}
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(requests[0].body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := payload.Data.(map[string]interface{}); !ok || data["webhook_id"] != webhook.ID {
+		t.Errorf("ping data = %+v, want webhook_id %s", payload.Data, webhook.ID)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter 滑动窗口限流器，按key独立计数
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+	now    func() time.Time
+}
+
+// NewRateLimiter 创建限流器，window时间内每个key最多允许limit次
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return NewRateLimiterWithClock(limit, window, time.Now)
+}
+
+// NewRateLimiterWithClock 使用指定时钟创建限流器，便于确定性测试
+func NewRateLimiterWithClock(limit int, window time.Duration, now func() time.Time) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+		now:    now,
+	}
+}
+
+// Allow 记录一次请求，超出限制时返回false
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	hits := l.recentLocked(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false
+	}
+
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// Forget 清除key的计数
+func (l *RateLimiter) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.hits, key)
+}
+
+// recentLocked 返回窗口内的请求记录，调用方需持有锁
+func (l *RateLimiter) recentLocked(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	start := 0
+	for start < len(hits) && now.Sub(hits[start]) >= l.window {
+		start++
+	}
+	if start == len(hits) {
+		delete(l.hits, key)
+		return nil
+	}
+	return hits[start:]
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+)
+
+// EmojiCatalog 预设的16个经典表情，回应只能使用其中的表情
+var EmojiCatalog = []models.Emoji{
+	{Code: "smile", Char: "😀", Name: "微笑"},
+	{Code: "laugh", Char: "😂", Name: "大笑"},
+	{Code: "wink", Char: "😉", Name: "眨眼"},
+	{Code: "love", Char: "😍", Name: "喜爱"},
+	{Code: "cool", Char: "😎", Name: "酷"},
+	{Code: "think", Char: "🤔", Name: "思考"},
+	{Code: "wow", Char: "😮", Name: "惊讶"},
+	{Code: "sad", Char: "😢", Name: "难过"},
+	{Code: "angry", Char: "😠", Name: "生气"},
+	{Code: "thumbs_up", Char: "👍", Name: "赞"},
+	{Code: "thumbs_down", Char: "👎", Name: "踩"},
+	{Code: "clap", Char: "👏", Name: "鼓掌"},
+	{Code: "heart", Char: "❤️", Name: "爱心"},
+	{Code: "fire", Char: "🔥", Name: "火"},
+	{Code: "star", Char: "⭐", Name: "星星"},
+	{Code: "party", Char: "🎉", Name: "庆祝"},
+}
+
+// IsValidEmoji 判断表情代码是否在目录中
+func IsValidEmoji(code string) bool {
+	for _, emoji := range EmojiCatalog {
+		if emoji.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleReaction 切换用户对某个表情的回应，返回新的回应列表，不修改原列表
+func toggleReaction(reactions []*models.Reaction, emoji string, userID string) []*models.Reaction {
+	result := make([]*models.Reaction, 0, len(reactions)+1)
+	found := false
+
+	for _, reaction := range reactions {
+		if reaction.Emoji != emoji {
+			result = append(result, reaction)
+			continue
+		}
+
+		found = true
+		userIDs := make([]string, 0, len(reaction.UserIDs)+1)
+		reacted := false
+		for _, id := range reaction.UserIDs {
+			if id == userID {
+				reacted = true
+				continue
+			}
+			userIDs = append(userIDs, id)
+		}
+		if !reacted {
+			userIDs = append(userIDs, userID)
+		}
+
+		// 没有人回应的表情直接移除
+		if len(userIDs) > 0 {
+			result = append(result, &models.Reaction{Emoji: emoji, Count: len(userIDs), UserIDs: userIDs})
+		}
+	}
+
+	if !found {
+		result = append(result, &models.Reaction{Emoji: emoji, Count: 1, UserIDs: []string{userID}})
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// readMarkerRetention 已读位置的保留时长，超过该时长未更新的记录会被清理
+	readMarkerRetention = 7 * 24 * time.Hour
+
+	// seenByActiveWindow 离线身份在该时长内更新过已读位置时仍计入已读人数
+	seenByActiveWindow = 15 * time.Minute
+)
+
+// readMarker 某个身份读到的最后一条消息
+type readMarker struct {
+	messageID string
+	timestamp time.Time // 消息的时间戳，消息超出历史窗口后仍可据此计算未读数
+	updatedAt time.Time
+}
+
+// ReadMarkerService 按持久身份记录已读位置
+type ReadMarkerService struct {
+	markers    map[string]*readMarker // 身份 -> 已读位置
+	markersMux sync.RWMutex
+	now        func() time.Time
+}
+
+func NewReadMarkerService() *ReadMarkerService {
+	return &ReadMarkerService{
+		markers: make(map[string]*readMarker),
+		now:     time.Now,
+	}
+}
+
+// Init 为没有已读记录的身份设置初始位置，已有记录时保持不变
+func (s *ReadMarkerService) Init(identity, messageID string, timestamp time.Time) {
+	s.markersMux.Lock()
+	defer s.markersMux.Unlock()
+
+	if marker, exists := s.markers[identity]; exists {
+		marker.updatedAt = s.now()
+		return
+	}
+	s.markers[identity] = &readMarker{messageID: messageID, timestamp: timestamp, updatedAt: s.now()}
+}
+
+// Mark 将已读位置前移到指定消息，早于当前位置的消息会被忽略，返回是否有变化
+func (s *ReadMarkerService) Mark(identity, messageID string, timestamp time.Time) bool {
+	s.markersMux.Lock()
+	defer s.markersMux.Unlock()
+
+	s.pruneLocked()
+
+	if marker, exists := s.markers[identity]; exists && !timestamp.After(marker.timestamp) {
+		marker.updatedAt = s.now()
+		return false
+	}
+	s.markers[identity] = &readMarker{messageID: messageID, timestamp: timestamp, updatedAt: s.now()}
+	return true
+}
+
+// Get 获取身份的已读位置
+func (s *ReadMarkerService) Get(identity string) (string, time.Time, bool) {
+	s.markersMux.RLock()
+	defer s.markersMux.RUnlock()
+
+	marker, exists := s.markers[identity]
+	if !exists {
+		return "", time.Time{}, false
+	}
+	return marker.messageID, marker.timestamp, true
+}
+
+// SeenBy 统计已读到指定时间的消息的身份数，只计入在线的身份和最近活跃过的身份
+func (s *ReadMarkerService) SeenBy(timestamp time.Time, online map[string]bool) int {
+	s.markersMux.RLock()
+	defer s.markersMux.RUnlock()
+
+	now := s.now()
+	count := 0
+	for identity, marker := range s.markers {
+		if marker.timestamp.Before(timestamp) {
+			continue
+		}
+		if online[identity] || now.Sub(marker.updatedAt) < seenByActiveWindow {
+			count++
+		}
+	}
+	return count
+}
+
+// pruneLocked 清理长期未更新的已读记录，调用方需持有写锁
+func (s *ReadMarkerService) pruneLocked() {
+	now := s.now()
+	for identity, marker := range s.markers {
+		if now.Sub(marker.updatedAt) >= readMarkerRetention {
+			delete(s.markers, identity)
+		}
+	}
+}
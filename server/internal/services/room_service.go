@@ -0,0 +1,154 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultRoomID 默认聊天室ID，目前所有用户都在该聊天室中
+	DefaultRoomID = "lobby"
+
+	// maxTopicLength 话题的最大字符数
+	maxTopicLength = 100
+
+	// maxMOTDLength 公告的最大字符数
+	maxMOTDLength = 1000
+
+	// maxPinnedMessages 每个聊天室最多置顶的消息数
+	maxPinnedMessages = 10
+)
+
+// RoomService 管理聊天室的话题、公告和置顶消息
+type RoomService struct {
+	rooms    map[string]*models.Room
+	roomsMux sync.RWMutex
+}
+
+func NewRoomService() *RoomService {
+	return &RoomService{
+		rooms: map[string]*models.Room{
+			DefaultRoomID: newRoom(DefaultRoomID),
+		},
+	}
+}
+
+// GetRoom 获取聊天室信息
+func (s *RoomService) GetRoom(roomID string) (*models.Room, bool) {
+	s.roomsMux.RLock()
+	defer s.roomsMux.RUnlock()
+
+	room, exists := s.rooms[roomID]
+	return room, exists
+}
+
+// UpdateRoom 修改聊天室的话题和公告，未提供的字段保持不变
+func (s *RoomService) UpdateRoom(roomID, updatedBy string, req *models.UpdateRoomRequest) (*models.Room, error) {
+	if req.Topic != nil && utf8.RuneCountInString(*req.Topic) > maxTopicLength {
+		return nil, newChatError(CodeInvalidRequest, "话题最多%d个字符", maxTopicLength)
+	}
+	if req.MOTD != nil && utf8.RuneCountInString(*req.MOTD) > maxMOTDLength {
+		return nil, newChatError(CodeInvalidRequest, "公告最多%d个字符", maxMOTDLength)
+	}
+
+	return s.updateRoom(roomID, updatedBy, func(room *models.Room) error {
+		if req.Topic != nil {
+			room.Topic = *req.Topic
+		}
+		if req.MOTD != nil {
+			room.MOTD = *req.MOTD
+		}
+		return nil
+	})
+}
+
+// PinMessage 置顶消息，保存消息快照以便消息超出历史窗口后仍可展示
+func (s *RoomService) PinMessage(roomID, updatedBy string, message *models.Message) (*models.Room, error) {
+	if message.Deleted {
+		return nil, newChatError(CodeMessageDeleted, "消息已撤回")
+	}
+
+	return s.updateRoom(roomID, updatedBy, func(room *models.Room) error {
+		for _, id := range room.PinnedIDs {
+			if id == message.ID {
+				return newChatError(CodeInvalidRequest, "消息已置顶")
+			}
+		}
+		if len(room.PinnedIDs) >= maxPinnedMessages {
+			return newChatError(CodeInvalidRequest, "最多置顶%d条消息", maxPinnedMessages)
+		}
+		room.PinnedIDs = append(append([]string{}, room.PinnedIDs...), message.ID)
+		room.Pinned = append(append([]*models.Message{}, room.Pinned...), message)
+		return nil
+	})
+}
+
+// UnpinMessage 取消置顶
+func (s *RoomService) UnpinMessage(roomID, updatedBy, messageID string) (*models.Room, error) {
+	return s.updateRoom(roomID, updatedBy, func(room *models.Room) error {
+		pinnedIDs := make([]string, 0, len(room.PinnedIDs))
+		pinned := make([]*models.Message, 0, len(room.Pinned))
+		for i, id := range room.PinnedIDs {
+			if id != messageID {
+				pinnedIDs = append(pinnedIDs, id)
+				pinned = append(pinned, room.Pinned[i])
+			}
+		}
+		if len(pinnedIDs) == len(room.PinnedIDs) {
+			return newChatError(CodeInvalidRequest, "消息未置顶")
+		}
+		room.PinnedIDs = pinnedIDs
+		room.Pinned = pinned
+		return nil
+	})
+}
+
+// RefreshPinned 消息被编辑或撤回后更新所有聊天室中的置顶快照
+func (s *RoomService) RefreshPinned(message *models.Message) {
+	s.roomsMux.Lock()
+	defer s.roomsMux.Unlock()
+
+	for roomID, room := range s.rooms {
+		for i, id := range room.PinnedIDs {
+			if id != message.ID {
+				continue
+			}
+			updated := *room
+			updated.Pinned = append([]*models.Message{}, room.Pinned...)
+			updated.Pinned[i] = message
+			s.rooms[roomID] = &updated
+			break
+		}
+	}
+}
+
+// updateRoom 复制聊天室后修改，已返回给调用方的聊天室不会被改动
+func (s *RoomService) updateRoom(roomID, updatedBy string, update func(room *models.Room) error) (*models.Room, error) {
+	s.roomsMux.Lock()
+	defer s.roomsMux.Unlock()
+
+	room, exists := s.rooms[roomID]
+	if !exists {
+		return nil, newChatError(CodeRoomNotFound, "聊天室不存在")
+	}
+
+	updated := *room
+	if err := update(&updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+	updated.UpdatedBy = updatedBy
+	s.rooms[roomID] = &updated
+	return &updated, nil
+}
+
+// newRoom 创建空的聊天室
+func newRoom(roomID string) *models.Room {
+	return &models.Room{
+		ID:        roomID,
+		PinnedIDs: []string{},
+		Pinned:    []*models.Message{},
+	}
+}
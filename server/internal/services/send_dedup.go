@@ -0,0 +1,76 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"sync"
+	"time"
+)
+
+const (
+	// sendDedupWindow 客户端消息ID的去重时长，覆盖断线重连后的重发
+	sendDedupWindow = 10 * time.Minute
+
+	// maxClientIDLength 客户端消息ID的最大长度
+	maxClientIDLength = 64
+)
+
+// sentMessage 某个客户端消息ID的发送记录，发送完成前done未关闭
+type sentMessage struct {
+	done    chan struct{}
+	message *models.Message
+	err     error
+}
+
+// SendDeduplicator 按客户端消息ID对发送去重，窗口内重复发送时返回首次发送的消息
+type SendDeduplicator struct {
+	mu     sync.Mutex
+	sent   map[string]*sentMessage
+	window time.Duration
+}
+
+func NewSendDeduplicator() *SendDeduplicator {
+	return &SendDeduplicator{
+		sent:   make(map[string]*sentMessage),
+		window: sendDedupWindow,
+	}
+}
+
+// Do 对同一key只执行一次send，返回的duplicate表示消息之前已发送过
+// 同时到达的重复请求等待首次发送的结果，不同key的发送互不阻塞；发送失败不记录，客户端可以重试
+func (d *SendDeduplicator) Do(key string, send func() (*models.Message, error)) (*models.Message, bool, error) {
+	d.mu.Lock()
+	if sent, exists := d.sent[key]; exists {
+		d.mu.Unlock()
+		<-sent.done
+		if sent.err != nil {
+			return nil, false, sent.err
+		}
+		return sent.message, true, nil
+	}
+	sent := &sentMessage{done: make(chan struct{})}
+	d.sent[key] = sent
+	d.mu.Unlock()
+
+	sent.message, sent.err = send()
+	close(sent.done)
+
+	if sent.err != nil {
+		d.forget(key, sent)
+		return nil, false, sent.err
+	}
+	// 窗口结束后清理记录
+	time.AfterFunc(d.window, func() {
+		d.forget(key, sent)
+	})
+	return sent.message, false, nil
+}
+
+// forget 删除key的发送记录，记录已被替换时保持不变
+func (d *SendDeduplicator) forget(key string, sent *sentMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sent[key] == sent {
+		delete(d.sent, key)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"pixel-chat-server/internal/models"
+	"time"
+)
+
+// 会话恢复：用户断线后，加入请求携带之前的会话令牌即可在ID保留期内恢复原来的用户ID和头像
+// 已离开用户的令牌记录在 UserService.departed 中，随资料快照一起过期
+
+// resumeLocked 根据已离开会话的令牌恢复用户，无法恢复时返回nil，调用方需持有写锁
+func (s *UserService) resumeLocked(socketID string, params UserParams) *models.User {
+	if params.ResumeToken == "" {
+		return nil
+	}
+	userID, exists := s.departed[params.ResumeToken]
+	if !exists {
+		return nil
+	}
+	profile, exists := s.profiles[userID]
+	if !exists || profile.IsOnline || !s.idAllocator.Reclaim(userID) {
+		return nil
+	}
+	delete(s.departed, params.ResumeToken)
+
+	user := *profile
+	user.SocketID = socketID
+	user.Nickname = params.Nickname
+	if params.Tripcode != "" {
+		user.Tripcode = params.Tripcode
+	}
+	user.IsAdmin = params.IsAdmin
+	user.JoinTime = time.Now()
+	user.LastActivity = time.Now()
+	user.IsOnline = true
+
+	s.users[socketID] = &user
+	s.sessions[user.SessionToken] = socketID
+	s.snapshotProfileLocked(&user)
+	return &user
+}
+
+// rememberSessionLocked 记录离开用户的会话令牌以便重连，调用方需持有写锁
+func (s *UserService) rememberSessionLocked(user *models.User) {
+	s.departed[user.SessionToken] = user.ID
+}
+
+// pruneSessionsLocked 清理资料快照已过期的会话令牌，调用方需持有写锁
+func (s *UserService) pruneSessionsLocked() {
+	for token, userID := range s.departed {
+		if _, exists := s.profiles[userID]; !exists {
+			delete(s.departed, token)
+		}
+	}
+}
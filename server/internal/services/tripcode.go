@@ -0,0 +1,56 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// tripcodeLength 公开标识的字符数
+	tripcodeLength = 10
+
+	// maxTripcodeSecretLength 秘密口令的最大字节数
+	maxTripcodeSecretLength = 128
+)
+
+// TripcodeService 根据秘密口令生成稳定的公开标识，无需注册即可证明身份
+type TripcodeService struct {
+	pepper []byte
+}
+
+// NewTripcodeService 创建身份标识服务，pepper为空时随机生成，重启后标识会改变
+func NewTripcodeService(pepper string) *TripcodeService {
+	if pepper == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			panic(fmt.Sprintf("生成tripcode pepper失败: %v", err))
+		}
+		log.Println("未配置TRIPCODE_PEPPER，使用随机值，重启后身份标识将改变")
+		return &TripcodeService{pepper: buf}
+	}
+	return &TripcodeService{pepper: []byte(pepper)}
+}
+
+// Split 将 nickname#secret 拆分为昵称和公开标识，秘密口令不会被保留
+func (s *TripcodeService) Split(raw string) (nickname string, tripcode string, err error) {
+	nickname, secret, found := strings.Cut(raw, "#")
+	if !found || secret == "" {
+		return nickname, "", nil
+	}
+	if len(secret) > maxTripcodeSecretLength {
+		return "", "", newChatError(CodeInvalidNickname, "身份口令最多%d个字符", maxTripcodeSecretLength)
+	}
+	return nickname, s.Derive(secret), nil
+}
+
+// Derive 使用服务端pepper对秘密口令做HMAC，得到形如 !Ab3dEf9xYz 的公开标识
+func (s *TripcodeService) Derive(secret string) string {
+	mac := hmac.New(sha256.New, s.pepper)
+	mac.Write([]byte(secret))
+	return "!" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:tripcodeLength]
+}
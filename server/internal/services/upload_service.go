@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"pixel-chat-server/internal/avatar"
+	"pixel-chat-server/internal/models"
+)
+
+const (
+	// MaxUploadBytes 上传图片的最大字节数
+	MaxUploadBytes = 2 * 1024 * 1024
+
+	// maxUploadDimension 上传图片的最大边长，避免解码超大图片耗尽内存
+	maxUploadDimension = 4096
+
+	// uploadPixelSide 像素化后图片的最长边像素数
+	uploadPixelSide = 64
+
+	// uploadRenderSide 保存的PNG最长边的目标像素数，每个像素按整数倍放大
+	uploadRenderSide = 256
+)
+
+// UploadService 将上传的图片像素化后按内容哈希保存到本地目录
+type UploadService struct {
+	dir string
+}
+
+// NewUploadService 创建上传服务，目录不存在时自动创建
+func NewUploadService(dir string) (*UploadService, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %v", err)
+	}
+	return &UploadService{dir: dir}, nil
+}
+
+// Save 校验并像素化图片，相同结果的图片只保存一份
+func (s *UploadService) Save(data []byte) (*models.ImageAsset, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, newChatError(CodeImageTooLarge, "图片过大，最大%dMB", MaxUploadBytes/1024/1024)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, newChatError(CodeInvalidImage, "仅支持PNG、JPEG和GIF格式的图片")
+	}
+	if cfg.Width == 0 || cfg.Height == 0 || cfg.Width > maxUploadDimension || cfg.Height > maxUploadDimension {
+		return nil, newChatError(CodeInvalidImage, "图片尺寸需在1到%d像素之间", maxUploadDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, newChatError(CodeInvalidImage, "%s图片解析失败", format)
+	}
+
+	pixelated := avatar.Pixelate(img, uploadPixelSide)
+	scale := min(uploadRenderSide/max(pixelated.Width, pixelated.Height), avatar.MaxScale)
+	rendered, err := avatar.RenderPNG(pixelated, scale)
+	if err != nil {
+		return nil, newChatError(CodeInternal, "图片渲染失败")
+	}
+
+	sum := sha256.Sum256(rendered)
+	id := hex.EncodeToString(sum[:])
+	path := s.Path(id)
+	if _, err := os.Stat(path); err != nil {
+		if err := writeFileAtomic(path, rendered); err != nil {
+			return nil, newChatError(CodeInternal, "保存图片失败")
+		}
+	}
+
+	return newImageAsset(id, pixelated.Width*scale, pixelated.Height*scale), nil
+}
+
+// Get 获取已保存的图片信息
+func (s *UploadService) Get(id string) (*models.ImageAsset, bool) {
+	if !validUploadID(id) {
+		return nil, false
+	}
+
+	file, err := os.Open(s.Path(id))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	cfg, err := png.DecodeConfig(file)
+	if err != nil {
+		return nil, false
+	}
+	return newImageAsset(id, cfg.Width, cfg.Height), true
+}
+
+// Path 返回图片在本地目录中的路径，按哈希前两位分子目录避免单目录文件过多
+func (s *UploadService) Path(id string) string {
+	return filepath.Join(s.dir, id[:2], id+".png")
+}
+
+// validUploadID 判断是否为合法的内容哈希
+func validUploadID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// newImageAsset 创建图片资源描述
+func newImageAsset(id string, width, height int) *models.ImageAsset {
+	return &models.ImageAsset{
+		ID:     id,
+		URL:    "/api/uploads/" + id + ".png",
+		Width:  width,
+		Height: height,
+	}
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免并发读取到不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
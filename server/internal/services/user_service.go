@@ -1,65 +1,126 @@
 package services
 
 import (
-	"fmt"
-	"math/rand"
+	"crypto/rand"
+	"encoding/hex"
+	"pixel-chat-server/internal/avatar"
 	"pixel-chat-server/internal/models"
 	"sync"
 	"time"
 )
 
 type UserService struct {
-	users    map[string]*models.User
-	usersMux sync.RWMutex
-	maxUsers int
+	users       map[string]*models.User
+	sessions    map[string]string       // 会话令牌 -> socketID
+	profiles    map[string]*models.User // 用户ID -> 最近的资料快照，包含已离开的用户
+	departed    map[string]string       // 已离开用户的会话令牌 -> 用户ID，用于重连恢复身份
+	usersMux    sync.RWMutex
+	maxUsers    int
+	idAllocator IDAllocator
 }
 
 func NewUserService() *UserService {
-	return &UserService{
-		users:    make(map[string]*models.User),
-		maxUsers: 100, // 默认最大用户数
-	}
+	return NewUserServiceWithAllocator(NewHexIDAllocator())
 }
 
-// GenerateUserID 生成用户ID
-func (s *UserService) GenerateUserID() string {
-	return fmt.Sprintf("User#%04X", rand.Intn(0xFFFF))
+// NewUserServiceWithAllocator 使用指定的ID分配器创建用户服务
+func NewUserServiceWithAllocator(idAllocator IDAllocator) *UserService {
+	return &UserService{
+		users:       make(map[string]*models.User),
+		sessions:    make(map[string]string),
+		profiles:    make(map[string]*models.User),
+		departed:    make(map[string]string),
+		maxUsers:    100, // 默认最大用户数
+		idAllocator: idAllocator,
+	}
 }
 
-// GenerateAvatar 生成像素头像
-func (s *UserService) GenerateAvatar() string {
-	// 生成8x8像素头像的16进制表示
-	colors := []string{"0", "1", "2", "3", "4", "5"} // 对应不同颜色
-	avatar := ""
-	
-	for i := 0; i < 16; i++ {
-		colorIndex := rand.Intn(len(colors))
-		avatar += colors[colorIndex]
-	}
-	
-	return avatar
+// UserParams 创建用户的参数
+type UserParams struct {
+	Nickname    string
+	Tripcode    string // 可选的公开身份标识
+	ResumeToken string // 之前会话的令牌，保留期内重连时恢复原来的用户ID和头像
+	IsAdmin     bool
+	Bot         *models.Bot // 机器人身份，设置时使用机器人的用户ID和头像，不恢复会话
 }
 
 // CreateUser 创建用户
-func (s *UserService) CreateUser(socketID string, nickname string) (*models.User, error) {
+func (s *UserService) CreateUser(socketID string, params UserParams) (*models.User, error) {
 	s.usersMux.Lock()
 	defer s.usersMux.Unlock()
 
 	if len(s.users) >= s.maxUsers {
-		return nil, fmt.Errorf("聊天室已满")
+		return nil, newChatError(CodeRoomFull, "聊天室已满")
+	}
+
+	s.pruneProfilesLocked()
+
+	if params.Bot != nil {
+		return s.createBotLocked(socketID, params)
+	}
+
+	if user := s.resumeLocked(socketID, params); user != nil {
+		return user, nil
+	}
+
+	userID, err := s.idAllocator.Allocate()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionToken, err := generateSessionToken()
+	if err != nil {
+		s.idAllocator.Release(userID)
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:           userID,
+		SocketID:     socketID,
+		Nickname:     params.Nickname,
+		Tripcode:     params.Tripcode,
+		Avatar:       avatar.Generate(userID).String(),
+		JoinTime:     time.Now(),
+		LastActivity: time.Now(),
+		IsOnline:     true,
+		IsAdmin:      params.IsAdmin,
+		SessionToken: sessionToken,
+	}
+
+	s.users[socketID] = user
+	s.sessions[sessionToken] = socketID
+	s.snapshotProfileLocked(user)
+	return user, nil
+}
+
+// createBotLocked 以机器人身份创建用户，同一机器人同时只能有一个连接，调用方需持有写锁
+func (s *UserService) createBotLocked(socketID string, params UserParams) (*models.User, error) {
+	for _, user := range s.users {
+		if user.ID == params.Bot.UserID {
+			return nil, newChatError(CodeInvalidRequest, "机器人已在线")
+		}
+	}
+
+	sessionToken, err := generateSessionToken()
+	if err != nil {
+		return nil, err
 	}
 
 	user := &models.User{
-		ID:           s.GenerateUserID(),
+		ID:           params.Bot.UserID,
 		SocketID:     socketID,
-		Nickname:     nickname,
-		Avatar:       s.GenerateAvatar(),
+		Nickname:     params.Nickname,
+		Avatar:       params.Bot.Avatar,
 		JoinTime:     time.Now(),
 		LastActivity: time.Now(),
 		IsOnline:     true,
+		IsBot:        true,
+		SessionToken: sessionToken,
 	}
 
 	s.users[socketID] = user
+	s.sessions[sessionToken] = socketID
+	s.snapshotProfileLocked(user)
 	return user, nil
 }
 
@@ -67,16 +128,100 @@ func (s *UserService) CreateUser(socketID string, nickname string) (*models.User
 func (s *UserService) GetUser(socketID string) (*models.User, bool) {
 	s.usersMux.RLock()
 	defer s.usersMux.RUnlock()
-	
+
 	user, exists := s.users[socketID]
 	return user, exists
 }
 
+// GetUserBySessionToken 根据会话令牌获取用户
+func (s *UserService) GetUserBySessionToken(token string) (*models.User, bool) {
+	s.usersMux.RLock()
+	defer s.usersMux.RUnlock()
+
+	socketID, exists := s.sessions[token]
+	if !exists {
+		return nil, false
+	}
+	user, exists := s.users[socketID]
+	return user, exists
+}
+
+// ProfileChanges 资料修改内容，空字段表示不修改
+type ProfileChanges struct {
+	Nickname      string
+	Tripcode      string
+	ClearTripcode bool
+	Avatar        string
+}
+
+// UpdateProfile 更新用户资料，返回更新后的用户副本
+func (s *UserService) UpdateProfile(socketID string, changes ProfileChanges) (*models.User, error) {
+	s.usersMux.Lock()
+	defer s.usersMux.Unlock()
+
+	user, exists := s.users[socketID]
+	if !exists {
+		return nil, newChatError(CodeUserNotFound, "用户不存在，请重新加入聊天室")
+	}
+
+	// 替换为新副本，避免与正在序列化旧对象的goroutine产生竞争
+	updated := *user
+	if changes.Nickname != "" {
+		updated.Nickname = changes.Nickname
+	}
+	if changes.Tripcode != "" {
+		updated.Tripcode = changes.Tripcode
+	}
+	if changes.ClearTripcode {
+		updated.Tripcode = ""
+	}
+	if changes.Avatar != "" {
+		updated.Avatar = changes.Avatar
+	}
+	s.users[socketID] = &updated
+	s.snapshotProfileLocked(&updated)
+	return &updated, nil
+}
+
+// GetProfiles 获取所有保留的用户资料，包括在线用户和保留期内离开的用户
+func (s *UserService) GetProfiles() []*models.User {
+	s.usersMux.RLock()
+	defer s.usersMux.RUnlock()
+
+	profiles := make([]*models.User, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// GetProfile 根据用户ID获取用户资料，已离开的用户在保留期内仍可查询
+func (s *UserService) GetProfile(userID string) (*models.User, bool) {
+	s.usersMux.RLock()
+	defer s.usersMux.RUnlock()
+
+	profile, exists := s.profiles[userID]
+	return profile, exists
+}
+
+// GetUserByID 根据用户ID获取用户
+func (s *UserService) GetUserByID(userID string) (*models.User, bool) {
+	s.usersMux.RLock()
+	defer s.usersMux.RUnlock()
+
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
 // UpdateUserActivity 更新用户活动时间
 func (s *UserService) UpdateUserActivity(socketID string) {
 	s.usersMux.Lock()
 	defer s.usersMux.Unlock()
-	
+
 	if user, exists := s.users[socketID]; exists {
 		user.LastActivity = time.Now()
 	}
@@ -86,10 +231,13 @@ func (s *UserService) UpdateUserActivity(socketID string) {
 func (s *UserService) RemoveUser(socketID string) *models.User {
 	s.usersMux.Lock()
 	defer s.usersMux.Unlock()
-	
+
 	user, exists := s.users[socketID]
 	if exists {
 		delete(s.users, socketID)
+		delete(s.sessions, user.SessionToken)
+		s.idAllocator.Release(user.ID)
+		s.markProfileOfflineLocked(user)
 	}
 	return user
 }
@@ -98,7 +246,7 @@ func (s *UserService) RemoveUser(socketID string) *models.User {
 func (s *UserService) GetAllUsers() []*models.User {
 	s.usersMux.RLock()
 	defer s.usersMux.RUnlock()
-	
+
 	users := make([]*models.User, 0, len(s.users))
 	for _, user := range s.users {
 		users = append(users, user)
@@ -110,7 +258,7 @@ func (s *UserService) GetAllUsers() []*models.User {
 func (s *UserService) GetOnlineUsers() []*models.User {
 	s.usersMux.RLock()
 	defer s.usersMux.RUnlock()
-	
+
 	users := make([]*models.User, 0)
 	for _, user := range s.users {
 		if user.IsOnline {
@@ -124,7 +272,7 @@ func (s *UserService) GetOnlineUsers() []*models.User {
 func (s *UserService) GetUsersCount() int {
 	s.usersMux.RLock()
 	defer s.usersMux.RUnlock()
-	
+
 	return len(s.users)
 }
 
@@ -132,11 +280,50 @@ func (s *UserService) GetUsersCount() int {
 func (s *UserService) CleanupInactiveUsers(timeout time.Duration) {
 	s.usersMux.Lock()
 	defer s.usersMux.Unlock()
-	
+
 	now := time.Now()
 	for socketID, user := range s.users {
 		if now.Sub(user.LastActivity) > timeout {
 			delete(s.users, socketID)
+			delete(s.sessions, user.SessionToken)
+			s.idAllocator.Release(user.ID)
+			s.markProfileOfflineLocked(user)
 		}
 	}
 }
+
+// snapshotProfileLocked 保存用户资料快照，调用方需持有写锁
+func (s *UserService) snapshotProfileLocked(user *models.User) {
+	profile := *user
+	s.profiles[user.ID] = &profile
+}
+
+// markProfileOfflineLocked 将用户资料快照标记为离线并保留会话令牌以便重连，调用方需持有写锁
+func (s *UserService) markProfileOfflineLocked(user *models.User) {
+	s.rememberSessionLocked(user)
+
+	profile := *user
+	profile.IsOnline = false
+	profile.LastActivity = time.Now()
+	s.profiles[user.ID] = &profile
+}
+
+// pruneProfilesLocked 清理超过保留期的离线用户资料，调用方需持有写锁
+func (s *UserService) pruneProfilesLocked() {
+	now := time.Now()
+	for userID, profile := range s.profiles {
+		if !profile.IsOnline && now.Sub(profile.LastActivity) > DefaultIDRetention {
+			delete(s.profiles, userID)
+		}
+	}
+	s.pruneSessionsLocked()
+}
+
+// generateSessionToken 生成随机会话令牌
+func generateSessionToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", newChatError(CodeInternal, "生成会话令牌失败")
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"pixel-chat-server/internal/models"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// 可协商的WebSocket子协议，未协商子协议的连接使用JSON文本帧
+const (
+	SubprotocolJSON    = "pixelchat.json"
+	SubprotocolMsgpack = "pixelchat.msgpack"
+	SubprotocolCBOR    = "pixelchat.cbor"
+)
+
+// Codec 消息的编解码方式，每个连接在握手时通过WebSocket子协议选定
+type Codec interface {
+	// Subprotocol 对应的WebSocket子协议
+	Subprotocol() string
+
+	// FrameType 发送消息使用的WebSocket帧类型
+	FrameType() int
+
+	// Encode 序列化消息
+	Encode(message *models.WebSocketMessage) ([]byte, error)
+
+	// Decode 解析客户端消息，data保留为原始编码，由DecodeData按事件类型解析
+	Decode(frame []byte) (*IncomingMessage, error)
+
+	// DecodeData 将原始编码的data解析到请求结构，data为空时不做任何修改
+	DecodeData(data []byte, v interface{}) error
+}
+
+// IncomingMessage 客户端消息，只解析一次，Data在确定事件类型后再解析
+type IncomingMessage struct {
+	Type      string
+	Data      []byte
+	RequestID string
+}
+
+// jsonInbound JSON客户端消息，data保留原始JSON
+type jsonInbound struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	RequestID string          `json:"request_id"`
+}
+
+// binaryInbound 二进制客户端消息，data保留原始编码
+type binaryInbound struct {
+	Type      string    `json:"type"`
+	Data      codec.Raw `json:"data"`
+	RequestID string    `json:"request_id"`
+}
+
+// jsonCodec JSON文本帧，兼容未协商子协议的旧客户端
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return SubprotocolJSON }
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Encode(message *models.WebSocketMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (jsonCodec) Decode(frame []byte) (*IncomingMessage, error) {
+	var message jsonInbound
+	if err := json.Unmarshal(frame, &message); err != nil {
+		return nil, err
+	}
+	return &IncomingMessage{Type: message.Type, Data: message.Data, RequestID: message.RequestID}, nil
+}
+
+func (jsonCodec) DecodeData(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// binaryCodec 基于ugorji/go/codec的二进制编码，使用二进制帧
+type binaryCodec struct {
+	subprotocol string
+	handle      codec.Handle
+}
+
+func (c *binaryCodec) Subprotocol() string { return c.subprotocol }
+
+func (c *binaryCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (c *binaryCodec) Encode(message *models.WebSocketMessage) ([]byte, error) {
+	var frame []byte
+	if err := codec.NewEncoderBytes(&frame, c.handle).Encode(message); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (c *binaryCodec) Decode(frame []byte) (*IncomingMessage, error) {
+	var message binaryInbound
+	if err := codec.NewDecoderBytes(frame, c.handle).Decode(&message); err != nil {
+		return nil, err
+	}
+	return &IncomingMessage{Type: message.Type, Data: message.Data, RequestID: message.RequestID}, nil
+}
+
+func (c *binaryCodec) DecodeData(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
+
+// newMsgpackCodec 创建MessagePack编码，使用新规范的str/bin类型和时间戳扩展
+func newMsgpackCodec() Codec {
+	return &binaryCodec{subprotocol: SubprotocolMsgpack, handle: &codec.MsgpackHandle{WriteExt: true}}
+}
+
+// newCBORCodec 创建CBOR编码，时间按RFC 3339字符串编码
+func newCBORCodec() Codec {
+	return &binaryCodec{subprotocol: SubprotocolCBOR, handle: &codec.CborHandle{TimeRFC3339: true}}
+}
+
+var (
+	// JSONCodec 默认编码
+	JSONCodec Codec = jsonCodec{}
+
+	// MsgpackCodec MessagePack编码
+	MsgpackCodec = newMsgpackCodec()
+
+	// CBORCodec CBOR编码
+	CBORCodec = newCBORCodec()
+)
+
+// codecs 服务端支持的编码，按协商时的优先顺序排列
+var codecs = []Codec{MsgpackCodec, CBORCodec, JSONCodec}
+
+// Subprotocols 返回服务端支持的WebSocket子协议，按优先顺序排列
+func Subprotocols() []string {
+	subprotocols := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		subprotocols = append(subprotocols, c.Subprotocol())
+	}
+	return subprotocols
+}
+
+// CodecFor 返回子协议对应的编码，未协商或不支持的子协议使用JSON
+func CodecFor(subprotocol string) Codec {
+	for _, c := range codecs {
+		if c.Subprotocol() == subprotocol {
+			return c
+		}
+	}
+	return JSONCodec
+}
+
+// outboundMessage 待发送的消息，按连接使用的编码分别序列化并缓存，只能在Hub.Run中访问
+type outboundMessage struct {
+	message models.WebSocketMessage
+	frames  map[Codec][]byte
+}
+
+// newOutboundMessage 创建待发送的消息，房间事件附带序号
+func newOutboundMessage(messageType string, data interface{}, seq uint64) *outboundMessage {
+	return &outboundMessage{
+		message: models.WebSocketMessage{
+			Type: messageType,
+			Data: data,
+			Seq:  seq,
+		},
+		frames: make(map[Codec][]byte, len(codecs)),
+	}
+}
+
+// frame 返回消息按指定编码序列化的结果，同一编码只序列化一次，失败时返回nil
+func (m *outboundMessage) frame(c Codec) []byte {
+	if frame, ok := m.frames[c]; ok {
+		return frame
+	}
+
+	frame, err := c.Encode(&m.message)
+	if err != nil {
+		log.Printf("序列化广播消息失败: %v", err)
+	}
+	m.frames[c] = frame
+	return frame
+}
@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"fmt"
+	"pixel-chat-server/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// benchRoomSize 基准测试中聊天室的在线人数
+	benchRoomSize = 100
+
+	// benchHistorySize 加入时下发的最近消息数
+	benchHistorySize = 50
+)
+
+// codecName 返回编码的简称，如 msgpack
+func codecName(c Codec) string {
+	return strings.TrimPrefix(c.Subprotocol(), "pixelchat.")
+}
+
+func TestCodecRequestRoundTrip(t *testing.T) {
+	requests := []struct {
+		eventType string
+		data      interface{}
+		decoded   func() interface{}
+	}{
+		{"join", &models.JoinRequest{Nickname: "像素玩家#secret", SessionToken: "abc", LastSeq: 1 << 40}, func() interface{} { return new(models.JoinRequest) }},
+		{"send_message", &models.SendMessageRequest{Content: "你好 @User#A3F2", ReplyTo: "m1", ClientID: "c1"}, func() interface{} { return new(models.SendMessageRequest) }},
+		{"update_profile", &models.UpdateProfileRequest{
+			Nickname:         "amy",
+			ClearTripcode:    true,
+			SetAvatarRequest: models.SetAvatarRequest{PNG: []byte{0x89, 'P', 'N', 'G', 0x00, 0xFF}, Size: 16},
+		}, func() interface{} { return new(models.UpdateProfileRequest) }},
+		{"hello", &models.HelloRequest{Versions: []int{1, 2}, Features: []string{"acks"}, Locale: "en"}, func() interface{} { return new(models.HelloRequest) }},
+	}
+
+	for _, c := range codecs {
+		for _, request := range requests {
+			t.Run(codecName(c)+"/"+request.eventType, func(t *testing.T) {
+				frame, err := c.Encode(&models.WebSocketMessage{Type: request.eventType, Data: request.data, RequestID: "r1"})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				incoming, err := c.Decode(frame)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if incoming.Type != request.eventType || incoming.RequestID != "r1" {
+					t.Fatalf("envelope = %q/%q, want %q/r1", incoming.Type, incoming.RequestID, request.eventType)
+				}
+
+				decoded := request.decoded()
+				if err := c.DecodeData(incoming.Data, decoded); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(decoded, request.data) {
+					t.Errorf("decoded = %+v, want %+v", decoded, request.data)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecDecodeDataWithoutData(t *testing.T) {
+	for _, c := range codecs {
+		frame, err := c.Encode(&models.WebSocketMessage{Type: "ping"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		incoming, err := c.Decode(frame)
+		if err != nil {
+			t.Fatalf("%s: %v", codecName(c), err)
+		}
+
+		request := models.SendMessageRequest{Content: "unchanged"}
+		if err := c.DecodeData(incoming.Data, &request); err != nil {
+			t.Fatalf("%s: %v", codecName(c), err)
+		}
+		if request.Content != "unchanged" {
+			t.Errorf("%s: empty data modified the request: %+v", codecName(c), request)
+		}
+	}
+}
+
+func TestCodecEncodeTime(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	edited := timestamp.Add(time.Minute)
+	event := models.NewMessageEvent{Message: &models.Message{
+		ID:        "m1",
+		Content:   "hello",
+		Timestamp: timestamp,
+		EditedAt:  &edited,
+		Type:      "text",
+	}}
+
+	for _, c := range codecs {
+		t.Run(codecName(c), func(t *testing.T) {
+			frame, err := c.Encode(&models.WebSocketMessage{Type: "new_message", Data: event, Seq: 7})
+			if err != nil {
+				t.Fatal(err)
+			}
+			incoming, err := c.Decode(frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var decoded models.NewMessageEvent
+			if err := c.DecodeData(incoming.Data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !decoded.Message.Timestamp.Equal(timestamp) {
+				t.Errorf("timestamp = %v, want %v", decoded.Message.Timestamp, timestamp)
+			}
+			if decoded.Message.EditedAt == nil || !decoded.Message.EditedAt.Equal(edited) {
+				t.Errorf("edited_at = %v, want %v", decoded.Message.EditedAt, edited)
+			}
+		})
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	for _, c := range codecs {
+		if CodecFor(c.Subprotocol()) != c {
+			t.Errorf("CodecFor(%q) returned a different codec", c.Subprotocol())
+		}
+	}
+	if CodecFor("") != JSONCodec || CodecFor("pixelchat.xml") != JSONCodec {
+		t.Error("unknown subprotocols should fall back to JSON")
+	}
+}
+
+// benchScenarios 比较编码开销的消息：加入响应、用户列表和新消息
+func benchScenarios() []struct {
+	name    string
+	message *models.WebSocketMessage
+} {
+	users, messages := benchRoom()
+	return []struct {
+		name    string
+		message *models.WebSocketMessage
+	}{
+		{"joined", &models.WebSocketMessage{Type: "joined", Data: models.JoinResponse{
+			User:     users[0],
+			Messages: messages,
+			Room:     &models.Room{ID: "lobby", Topic: "像素聊天室", PinnedIDs: []string{}},
+			Seq:      1024,
+		}}},
+		{"user_list", &models.WebSocketMessage{Type: "user_list", Seq: 1025, Data: models.UserListEvent{Users: users}}},
+		{"new_message", &models.WebSocketMessage{Type: "new_message", Seq: 1026, Data: models.NewMessageEvent{Message: messages[0]}}},
+	}
+}
+
+// BenchmarkEncode 比较各编码的序列化开销，bytes/frame为单帧字节数，broadcast-bytes为100人广播的总字节数
+func BenchmarkEncode(b *testing.B) {
+	for _, c := range codecs {
+		for _, scenario := range benchScenarios() {
+			b.Run(codecName(c)+"/"+scenario.name, func(b *testing.B) {
+				frame, err := c.Encode(scenario.message)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Encode(scenario.message)
+				}
+				// 广播时每种编码只序列化一次，传输字节随人数线性增长
+				b.ReportMetric(float64(len(frame)), "bytes/frame")
+				b.ReportMetric(float64(len(frame)*benchRoomSize), "broadcast-bytes")
+			})
+		}
+	}
+}
+
+// BenchmarkDecode 比较各编码解析消息外层结构的开销
+func BenchmarkDecode(b *testing.B) {
+	for _, c := range codecs {
+		for _, scenario := range benchScenarios() {
+			b.Run(codecName(c)+"/"+scenario.name, func(b *testing.B) {
+				frame, err := c.Encode(scenario.message)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Decode(frame)
+				}
+			})
+		}
+	}
+}
+
+// benchRoom 生成在线用户和最近消息
+func benchRoom() ([]*models.User, []*models.Message) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	users := make([]*models.User, 0, benchRoomSize)
+	for i := 0; i < benchRoomSize; i++ {
+		users = append(users, &models.User{
+			ID:           fmt.Sprintf("User#%04X", i),
+			SocketID:     fmt.Sprintf("00000000-0000-4000-8000-%012d", i),
+			Nickname:     fmt.Sprintf("像素玩家%d", i),
+			Avatar:       strings.Repeat("0123456789abcdef", 8),
+			JoinTime:     now,
+			LastActivity: now.Add(time.Duration(i) * time.Second),
+			IsOnline:     true,
+		})
+	}
+
+	messages := make([]*models.Message, 0, benchHistorySize)
+	for i := 0; i < benchHistorySize; i++ {
+		user := users[i%benchRoomSize]
+		messages = append(messages, &models.Message{
+			ID:           fmt.Sprintf("00000000-0000-4000-9000-%012d", i),
+			UserID:       user.ID,
+			UserNickname: user.Nickname,
+			UserAvatar:   user.Avatar,
+			Content:      "大家好，今天一起画点什么？ :smile:",
+			Timestamp:    now.Add(time.Duration(i) * time.Minute),
+			Seq:          uint64(i + 1),
+			Type:         "text",
+			Reactions:    []*models.Reaction{{Emoji: "👍", Count: 2, UserIDs: []string{users[0].ID, users[1].ID}}},
+		})
+	}
+	return users, messages
+}
@@ -0,0 +1,54 @@
+package websocket
+
+// eventLogSize 保留的房间事件数，断线重连时可补发这些事件
+const eventLogSize = 1000
+
+// loggedEvent 已广播的房间事件
+type loggedEvent struct {
+	seq     uint64
+	message *outboundMessage
+}
+
+// eventLog 按序号保存最近广播的房间事件，只能在Hub.Run中访问
+type eventLog struct {
+	events []loggedEvent
+	start  int // 环形缓冲区中最早事件的位置
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{
+		events: make([]loggedEvent, 0, eventLogSize),
+	}
+}
+
+// append 记录事件，超出容量时覆盖最早的事件
+func (l *eventLog) append(seq uint64, message *outboundMessage) {
+	if len(l.events) < eventLogSize {
+		l.events = append(l.events, loggedEvent{seq: seq, message: message})
+		return
+	}
+	l.events[l.start] = loggedEvent{seq: seq, message: message}
+	l.start = (l.start + 1) % eventLogSize
+}
+
+// since 返回序号大于lastSeq的全部事件，lastSeq之后的事件已被淘汰或lastSeq超出当前序号时返回false
+func (l *eventLog) since(lastSeq, currentSeq uint64) ([]*outboundMessage, bool) {
+	if lastSeq > currentSeq {
+		return nil, false
+	}
+	if lastSeq == currentSeq {
+		return nil, true
+	}
+	if len(l.events) == 0 || l.events[l.start].seq > lastSeq+1 {
+		return nil, false
+	}
+
+	messages := make([]*outboundMessage, 0, currentSeq-lastSeq)
+	for i := 0; i < len(l.events); i++ {
+		event := l.events[(l.start+i)%len(l.events)]
+		if event.seq > lastSeq {
+			messages = append(messages, event.message)
+		}
+	}
+	return messages, true
+}
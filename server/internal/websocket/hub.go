@@ -1,11 +1,14 @@
 package websocket
 
 import (
-	"encoding/json"
+	"compress/flate"
+	"fmt"
 	"log"
 	"net/http"
+	"pixel-chat-server/internal/bots"
 	"pixel-chat-server/internal/models"
 	"pixel-chat-server/internal/services"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,8 +24,8 @@ const (
 	// 发送ping消息的间隔时间，必须小于pongWait
 	pingPeriod = (pongWait * 9) / 10
 
-	// 最大消息大小
-	maxMessageSize = 512
+	// 最大消息大小，需容纳base64编码的头像PNG
+	maxMessageSize = 32 * 1024
 )
 
 var upgrader = websocket.Upgrader{
@@ -39,30 +42,104 @@ type Client struct {
 	conn     *websocket.Conn
 	send     chan []byte
 	socketID string
+	codec    Codec // 握手时协商的编码，连接期间不变
+
+	// 以下字段只能在Hub.Run中访问
+	subscribed     bool       // 是否接收房间事件，加入聊天室后才开始接收
+	subscribedWith featureSet // 加入时启用的功能，决定推送哪些事件
+
+	// 以下字段只在readPump中访问
+	protocolVersion int
+	locale          string
+	features        featureSet // 握手时协商的功能，未握手时为nil
+	requestID       string     // 正在处理的请求ID，直接回复会带回该ID
+}
+
+// directMessage 发送给指定连接的消息，在Hub.Run中按连接的编码序列化
+type directMessage struct {
+	socketID string
+	message  *outboundMessage
+}
+
+// roomEvent 待广播的房间事件，由Hub.Run分配序号后序列化
+type roomEvent struct {
+	eventType string
+	data      interface{}
+}
+
+// subscription 开始接收房间事件的请求，lastSeq之后的事件会先补发
+type subscription struct {
+	client   *Client
+	lastSeq  uint64
+	features featureSet
 }
 
 // Hub 维护活跃的客户端和广播消息
 type Hub struct {
 	clients     map[*Client]bool
-	broadcast   chan []byte
+	broadcast   chan roomEvent
+	direct      chan directMessage
+	typing      chan typingUpdate
+	subscribe   chan subscription
 	register    chan *Client
 	unregister  chan *Client
 	chatService *services.ChatService
+	streams     map[*Stream]bool // 只读订阅，只能在Run中访问
+	typers      *typingState
+	events      *eventLog
+	seq         atomic.Uint64 // 房间事件的最新序号，只在Run中递增
+	options     HubOptions
+
+	streamSubscribe   chan *Stream
+	streamUnsubscribe chan *Stream
+}
+
+// HubOptions Hub的可选配置
+type HubOptions struct {
+	Compression          bool // 是否与客户端协商permessage-deflate压缩
+	CompressionLevel     int  // 压缩级别，1最快，9压缩率最高
+	CompressionThreshold int  // 小于该字节数的消息不压缩，避免小消息白白消耗CPU
+
+	Bots *bots.Registry // 接收新消息的进程内机器人，为空时不启用
 }
 
-// NewHub 创建新的Hub
-func NewHub(chatService *services.ChatService) *Hub {
+// NewHubWith 按配置创建新的Hub
+func NewHubWith(chatService *services.ChatService, options HubOptions) *Hub {
+	// 压缩级别只接受1-9，flate允许的-2到0（仅Huffman、默认、不压缩）不对外开放
+	if options.Compression && (options.CompressionLevel < flate.BestSpeed || options.CompressionLevel > flate.BestCompression) {
+		log.Printf("无效的WebSocket压缩级别 %d，使用 %d", options.CompressionLevel, flate.BestSpeed)
+		options.CompressionLevel = flate.BestSpeed
+	}
+
 	return &Hub{
 		clients:     make(map[*Client]bool),
-		broadcast:   make(chan []byte),
+		broadcast:   make(chan roomEvent),
+		direct:      make(chan directMessage),
+		typing:      make(chan typingUpdate),
+		subscribe:   make(chan subscription),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		chatService: chatService,
+		streams:     make(map[*Stream]bool),
+		typers:      newTypingState(),
+		events:      newEventLog(),
+		options:     options,
+
+		streamSubscribe:   make(chan *Stream),
+		streamUnsubscribe: make(chan *Stream),
 	}
 }
 
+// CompressionEnabled 是否在握手时协商permessage-deflate压缩
+func (h *Hub) CompressionEnabled() bool {
+	return h.options.Compression
+}
+
 // Run 启动Hub
 func (h *Hub) Run() {
+	typingTicker := time.NewTicker(typingFlushInterval)
+	defer typingTicker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -76,22 +153,52 @@ func (h *Hub) Run() {
 				log.Printf("客户端断开: %s", client.socketID)
 
 				// 从用户服务中移除用户
-				user := h.chatService.RemoveUser(client.socketID)
+				user, systemMessage := h.chatService.RemoveUser(client.socketID)
 				if user != nil {
-					// 广播用户离开事件
-					userLeftEvent := models.UserLeftEvent{User: user}
-					h.broadcastMessage("user_left", userLeftEvent)
+					h.typers.set(user.ID, false, time.Now())
+
+					// 在Run中不能写入h.broadcast，否则会阻塞自身，直接发布
+					userLeftEvent := models.UserLeftEvent{User: user, MessageID: systemMessage.ID}
+					h.publish(roomEvent{eventType: "user_left", data: userLeftEvent})
 
 					// 广播用户列表更新
 					userListEvent := models.UserListEvent{Users: h.chatService.GetOnlineUsers()}
-					h.broadcastMessage("user_list", userListEvent)
+					h.publish(roomEvent{eventType: "user_list", data: userListEvent})
 				}
 			}
 
-		case message := <-h.broadcast:
+		case event := <-h.broadcast:
+			h.publish(event)
+
+		case sub := <-h.subscribe:
+			h.subscribeClient(sub)
+
+		case stream := <-h.streamSubscribe:
+			h.subscribeStream(stream)
+
+		case stream := <-h.streamUnsubscribe:
+			h.unsubscribeStream(stream)
+
+		case update := <-h.typing:
+			h.typers.set(update.userID, update.typing, time.Now())
+
+		case now := <-typingTicker.C:
+			// 输入状态是临时状态，不分配序号也不补发
+			if userIDs, changed := h.typers.flush(now); changed {
+				h.fanOut(newOutboundMessage("typing", models.TypingEvent{UserIDs: userIDs}, 0))
+			}
+
+		case dm := <-h.direct:
 			for client := range h.clients {
+				if client.socketID != dm.socketID || !client.subscribedWith.allowsEvent(dm.message.message.Type) {
+					continue
+				}
+				frame := dm.message.frame(client.codec)
+				if frame == nil {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- frame:
 				default:
 					close(client.send)
 					delete(h.clients, client)
@@ -101,13 +208,107 @@ func (h *Hub) Run() {
 	}
 }
 
-// HandleWebSocket 处理WebSocket连接
+// publish 为房间事件分配序号并记录后分发，只能在Run中调用
+func (h *Hub) publish(event roomEvent) {
+	seq := h.seq.Add(1)
+
+	// 新消息的序号同时记录到消息上，历史消息可据此与事件对应
+	// 加入和离开的系统消息不单独广播，使用对应的user_joined和user_left事件的序号
+	switch data := event.data.(type) {
+	case models.NewMessageEvent:
+		if data.Message != nil {
+			message := *data.Message
+			message.Seq = seq
+			h.chatService.SetMessageSeq(message.ID, seq)
+			event.data = models.NewMessageEvent{Message: &message}
+		}
+	case models.UserJoinedEvent:
+		h.chatService.SetMessageSeq(data.MessageID, seq)
+	case models.UserLeftEvent:
+		h.chatService.SetMessageSeq(data.MessageID, seq)
+	}
+
+	message := newOutboundMessage(event.eventType, event.data, seq)
+	h.events.append(seq, message)
+	h.fanOut(message)
+}
+
+// subscribeClient 补发客户端错过的事件后开始推送房间事件，只能在Run中调用
+// 错过的事件已被淘汰时发送resync_required，客户端应以joined响应中的数据为准重新同步
+func (h *Hub) subscribeClient(sub subscription) {
+	client := sub.client
+	if _, ok := h.clients[client]; !ok || client.subscribed {
+		return
+	}
+
+	currentSeq := h.seq.Load()
+	messages, ok := h.events.since(sub.lastSeq, currentSeq)
+	if !ok {
+		messages = []*outboundMessage{newOutboundMessage("resync_required", models.ResyncRequiredEvent{
+			LastSeq:    sub.lastSeq,
+			CurrentSeq: currentSeq,
+		}, 0)}
+	}
+
+	for _, message := range messages {
+		if !sub.features.allowsEvent(message.message.Type) {
+			continue
+		}
+		frame := message.frame(client.codec)
+		if frame == nil {
+			continue
+		}
+		select {
+		case client.send <- frame:
+		default:
+			close(client.send)
+			delete(h.clients, client)
+			return
+		}
+	}
+	client.subscribed = true
+	client.subscribedWith = sub.features
+}
+
+// fanOut 将消息分发给所有已加入聊天室的客户端和只读订阅者，每种编码只序列化一次，只能在Run中调用
+// 属于某项功能的事件只发给启用了该功能的客户端
+func (h *Hub) fanOut(message *outboundMessage) {
+	for stream := range h.streams {
+		h.sendToStream(stream, message)
+	}
+	for client := range h.clients {
+		if !client.subscribed || !client.subscribedWith.allowsEvent(message.message.Type) {
+			continue
+		}
+		frame := message.frame(client.codec)
+		if frame == nil {
+			continue
+		}
+		select {
+		case client.send <- frame:
+		default:
+			close(client.send)
+			delete(h.clients, client)
+		}
+	}
+}
+
+// HandleWebSocket 处理WebSocket连接，按握手时协商的子协议选择编码
 func (h *Hub) HandleWebSocket(conn *websocket.Conn, socketID string) {
 	client := &Client{
 		hub:      h,
 		conn:     conn,
 		send:     make(chan []byte, 256),
 		socketID: socketID,
+		codec:    CodecFor(conn.Subprotocol()),
+
+		protocolVersion: legacyProtocolVersion,
+		locale:          services.LocaleZH,
+	}
+
+	// 只有客户端同意压缩时设置才会生效
+	if h.options.Compression {
+		conn.SetCompressionLevel(h.options.CompressionLevel)
 	}
 
 	client.hub.register <- client
@@ -162,8 +363,9 @@ func (c *Client) writePump() {
 				return
 			}
 
-			// 直接发送单个消息，避免批量发送导致的JSON解析问题
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			// 直接发送单个消息，避免批量发送导致的解析问题；只压缩达到阈值的消息
+			c.conn.EnableWriteCompression(c.hub.options.Compression && len(message) >= c.hub.options.CompressionThreshold)
+			if err := c.conn.WriteMessage(c.codec.FrameType(), message); err != nil {
 				return
 			}
 
@@ -178,49 +380,114 @@ func (c *Client) writePump() {
 
 // handleMessage 处理接收到的消息
 func (c *Client) handleMessage(messageBytes []byte) {
-	var wsMessage models.WebSocketMessage
-	if err := json.Unmarshal(messageBytes, &wsMessage); err != nil {
+	wsMessage, err := c.codec.Decode(messageBytes)
+	if err != nil {
 		log.Printf("解析消息失败: %v", err)
+		if c.protocolVersion >= ProtocolVersion {
+			c.sendErrorCode(services.CodeInvalidFrame, "无法解析的消息")
+		}
+		return
+	}
+
+	c.requestID = wsMessage.RequestID
+	defer func() {
+		c.requestID = ""
+	}()
+
+	if feature, allowed := c.features.allowsRequest(wsMessage.Type); !allowed {
+		c.sendErrorCode(services.CodeFeatureDisabled, fmt.Sprintf("握手时未启用功能: %s", feature))
 		return
 	}
 
 	switch wsMessage.Type {
+	case "hello":
+		c.handleHello(wsMessage.Data)
 	case "join":
 		c.handleJoin(wsMessage.Data)
 	case "send_message":
 		c.handleSendMessage(wsMessage.Data)
+	case "edit_message":
+		c.handleEditMessage(wsMessage.Data)
+	case "delete_message":
+		c.handleDeleteMessage(wsMessage.Data)
+	case "update_room":
+		c.handleUpdateRoom(wsMessage.Data)
+	case "pin_message":
+		c.handlePinMessage(wsMessage.Data, true)
+	case "unpin_message":
+		c.handlePinMessage(wsMessage.Data, false)
+	case "get_message_history":
+		c.handleGetMessageHistory(wsMessage.Data)
+	case "get_thread":
+		c.handleGetThread(wsMessage.Data)
+	case "mark_read":
+		c.handleMarkRead(wsMessage.Data)
+	case "get_mentions":
+		c.handleGetMentions()
+	case "clear_mentions":
+		c.handleClearMentions()
+	case "react":
+		c.handleReact(wsMessage.Data)
+	case "report_message":
+		c.handleReportMessage(wsMessage.Data)
+	case "set_avatar":
+		c.handleSetAvatar(wsMessage.Data)
+	case "update_profile":
+		c.handleUpdateProfile(wsMessage.Data)
+	case "typing_start":
+		c.handleTyping(true)
+	case "typing_stop":
+		c.handleTyping(false)
 	case "leave":
 		c.handleLeave()
 	case "ping":
 		c.handlePing()
+	default:
+		// 旧版本协议忽略未知事件，保持兼容
+		if c.protocolVersion >= ProtocolVersion {
+			c.sendErrorCode(services.CodeUnknownEvent, fmt.Sprintf("未知的事件类型: %s", wsMessage.Type))
+		}
 	}
 }
 
 // handleJoin 处理用户加入
-func (c *Client) handleJoin(data interface{}) {
-	dataBytes, _ := json.Marshal(data)
+func (c *Client) handleJoin(data []byte) {
 	var joinReq models.JoinRequest
-	if err := json.Unmarshal(dataBytes, &joinReq); err != nil {
-		c.sendError("无效的加入请求")
+	if err := c.decode(data, &joinReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的加入请求")
 		return
 	}
 
-	user, err := c.hub.chatService.AddUser(c.socketID, joinReq.Nickname)
+	user, systemMessage, err := c.hub.chatService.AddUser(c.socketID, &joinReq)
 	if err != nil {
-		c.sendError(err.Error())
+		c.sendError(err)
 		return
 	}
 
-	// 发送加入成功响应
+	// 发送加入成功响应，Seq之后的房间事件会在响应之后推送
+	// 先读取序号再获取历史，两者之间发布的消息会同时出现在历史和补发的事件中，客户端按消息ID去重
+	seq := c.hub.seq.Load()
 	response := models.JoinResponse{
-		User:     user,
-		Messages: c.hub.chatService.GetRecentMessages(50),
+		User:           user,
+		Messages:       c.hub.chatService.GetRecentMessages(50),
+		SessionToken:   user.SessionToken,
+		UnreadMentions: c.hub.chatService.GetUnreadMentions(user.ID),
+		Unread:         c.hub.chatService.GetUnread(user),
+		Room:           c.hub.chatService.GetRoom(),
+		Seq:            seq,
 	}
 
 	c.sendMessage("joined", response)
 
+	// 重连时从客户端收到的最后一个事件开始补发，否则从响应对应的序号开始
+	lastSeq := response.Seq
+	if joinReq.LastSeq > 0 {
+		lastSeq = joinReq.LastSeq
+	}
+	c.hub.subscribe <- subscription{client: c, lastSeq: lastSeq, features: c.features}
+
 	// 广播用户加入事件
-	userJoinedEvent := models.UserJoinedEvent{User: user}
+	userJoinedEvent := models.UserJoinedEvent{User: user, MessageID: systemMessage.ID}
 	c.hub.broadcastMessage("user_joined", userJoinedEvent)
 
 	// 广播用户列表更新
@@ -229,32 +496,311 @@ func (c *Client) handleJoin(data interface{}) {
 }
 
 // handleSendMessage 处理发送消息
-func (c *Client) handleSendMessage(data interface{}) {
-	dataBytes, _ := json.Marshal(data)
+func (c *Client) handleSendMessage(data []byte) {
 	var sendReq models.SendMessageRequest
-	if err := json.Unmarshal(dataBytes, &sendReq); err != nil {
-		c.sendError("无效的消息请求")
+	if err := c.decode(data, &sendReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的消息请求")
 		return
 	}
 
-	message, err := c.hub.chatService.SendMessage(c.socketID, sendReq.Content)
+	message, duplicate, err := c.hub.chatService.SendMessage(c.socketID, &sendReq)
 	if err != nil {
-		c.sendError(err.Error())
+		// 携带客户端消息ID时以nack回复，便于客户端匹配请求
+		if sendReq.ClientID != "" {
+			c.sendMessage("nack", models.NackEvent{
+				ClientID: sendReq.ClientID,
+				Code:     services.ErrorCode(err),
+				Message:  services.LocalizeError(services.ErrorCode(err), err.Error(), c.locale),
+			})
+			return
+		}
+		c.sendError(err)
 		return
 	}
 
-	// 广播新消息
-	newMessageEvent := models.NewMessageEvent{Message: message}
-	c.hub.broadcastMessage("new_message", newMessageEvent)
+	c.sendMessage("ack", models.AckEvent{
+		ClientID:  sendReq.ClientID,
+		MessageID: message.ID,
+		Timestamp: message.Timestamp,
+		Duplicate: duplicate,
+	})
+	if duplicate {
+		return
+	}
+
+	// 广播新消息，发送后不再处于输入状态
+	c.hub.BroadcastNewMessage(message)
+	c.hub.setTyping(message.UserID, false)
+}
+
+// handleEditMessage 处理编辑消息
+func (c *Client) handleEditMessage(data []byte) {
+	var editReq models.EditMessageRequest
+	if err := c.decode(data, &editReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的编辑请求")
+		return
+	}
+
+	revision, err := c.hub.chatService.EditMessage(c.socketID, &editReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	// 广播消息编辑事件
+	messageEditedEvent := models.MessageEditedEvent{Message: revision.Message}
+	c.hub.broadcastMessage("message_edited", messageEditedEvent)
+	c.hub.broadcastRevision(revision)
+}
+
+// handleDeleteMessage 处理撤回消息
+func (c *Client) handleDeleteMessage(data []byte) {
+	var deleteReq models.DeleteMessageRequest
+	if err := c.decode(data, &deleteReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的撤回请求")
+		return
+	}
+
+	revision, err := c.hub.chatService.DeleteMessage(c.socketID, &deleteReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	// 广播消息撤回事件
+	messageDeletedEvent := models.MessageDeletedEvent{
+		MessageID: revision.Message.ID,
+		Message:   revision.Message,
+	}
+	c.hub.broadcastMessage("message_deleted", messageDeletedEvent)
+	c.hub.broadcastRevision(revision)
+}
+
+// handleGetMessageHistory 处理获取消息编辑历史，仅管理员可用
+func (c *Client) handleGetMessageHistory(data []byte) {
+	if _, ok := c.adminUser(); !ok {
+		return
+	}
+
+	var historyReq models.MessageHistoryRequest
+	if err := c.decode(data, &historyReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的历史请求")
+		return
+	}
+
+	history, err := c.hub.chatService.GetMessageHistory(historyReq.MessageID)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.sendMessage("message_history", history)
+}
+
+// handleUpdateRoom 处理修改聊天室话题和公告（仅管理员）
+func (c *Client) handleUpdateRoom(data []byte) {
+	user, ok := c.adminUser()
+	if !ok {
+		return
+	}
+
+	var roomReq models.UpdateRoomRequest
+	if err := c.decode(data, &roomReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的聊天室请求")
+		return
+	}
+
+	room, err := c.hub.chatService.UpdateRoom(user.ID, &roomReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.hub.BroadcastRoomUpdated(room)
+}
+
+// handlePinMessage 处理置顶和取消置顶消息（仅管理员）
+func (c *Client) handlePinMessage(data []byte, pin bool) {
+	user, ok := c.adminUser()
+	if !ok {
+		return
+	}
+
+	var pinReq models.PinMessageRequest
+	if err := c.decode(data, &pinReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的置顶请求")
+		return
+	}
+
+	var room *models.Room
+	var err error
+	if pin {
+		room, err = c.hub.chatService.PinMessage(user.ID, &pinReq)
+	} else {
+		room, err = c.hub.chatService.UnpinMessage(user.ID, &pinReq)
+	}
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.hub.BroadcastRoomUpdated(room)
+}
+
+// adminUser 获取当前连接的管理员用户，不是管理员时发送错误
+func (c *Client) adminUser() (*models.User, bool) {
+	user, exists := c.hub.chatService.GetUser(c.socketID)
+	if !exists || !user.IsAdmin {
+		c.sendErrorCode(services.CodeForbidden, "没有权限")
+		return nil, false
+	}
+	return user, true
+}
+
+// handleGetThread 处理获取话题
+func (c *Client) handleGetThread(data []byte) {
+	var threadReq models.GetThreadRequest
+	if err := c.decode(data, &threadReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的话题请求")
+		return
+	}
+
+	thread, err := c.hub.chatService.GetThread(threadReq.MessageID)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.sendMessage("thread", thread)
+}
+
+// handleGetMentions 处理获取未读提及
+func (c *Client) handleGetMentions() {
+	user, exists := c.hub.chatService.GetUser(c.socketID)
+	if !exists {
+		c.sendErrorCode(services.CodeUserNotFound, "用户不存在，请重新加入聊天室")
+		return
+	}
+
+	c.sendMessage("mentions", c.hub.chatService.GetUnreadMentions(user.ID))
+}
+
+// handleMarkRead 处理标记已读
+func (c *Client) handleMarkRead(data []byte) {
+	var markReq models.MarkReadRequest
+	if err := c.decode(data, &markReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的已读请求")
+		return
+	}
+
+	unread, seen, err := c.hub.chatService.MarkRead(c.socketID, &markReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.sendMessage("unread", unread)
+	if seen != nil {
+		c.hub.broadcastMessage("message_seen", seen)
+	}
+}
+
+// handleClearMentions 处理清空未读提及
+func (c *Client) handleClearMentions() {
+	if err := c.hub.chatService.ClearMentions(c.socketID); err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.sendMessage("mentions", &models.MentionSummary{MessageIDs: []string{}})
+}
+
+// handleReact 处理表情回应
+func (c *Client) handleReact(data []byte) {
+	var reactReq models.ReactRequest
+	if err := c.decode(data, &reactReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的回应请求")
+		return
+	}
+
+	message, err := c.hub.chatService.ToggleReaction(c.socketID, &reactReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	// 广播回应更新
+	reactionUpdatedEvent := models.ReactionUpdatedEvent{
+		MessageID: message.ID,
+		Reactions: message.Reactions,
+	}
+	c.hub.broadcastMessage("reaction_updated", reactionUpdatedEvent)
+}
+
+// handleReportMessage 处理举报消息，举报只回复给举报者，并通过传出webhook通知管理员
+func (c *Client) handleReportMessage(data []byte) {
+	var reportReq models.ReportMessageRequest
+	if err := c.decode(data, &reportReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的举报请求")
+		return
+	}
+
+	report, err := c.hub.chatService.ReportMessage(c.socketID, &reportReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.sendMessage("reported", report)
+}
+
+// handleSetAvatar 处理设置头像
+func (c *Client) handleSetAvatar(data []byte) {
+	var avatarReq models.SetAvatarRequest
+	if err := c.decode(data, &avatarReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的头像请求")
+		return
+	}
+
+	user, err := c.hub.chatService.SetAvatar(c.socketID, &avatarReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.hub.BroadcastUserUpdated(user)
+}
+
+// handleUpdateProfile 处理修改资料
+func (c *Client) handleUpdateProfile(data []byte) {
+	var profileReq models.UpdateProfileRequest
+	if err := c.decode(data, &profileReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的资料请求")
+		return
+	}
+
+	user, systemMessage, err := c.hub.chatService.UpdateProfile(c.socketID, &profileReq)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	c.hub.BroadcastUserUpdated(user)
+	if systemMessage != nil {
+		newMessageEvent := models.NewMessageEvent{Message: systemMessage}
+		c.hub.broadcastMessage("new_message", newMessageEvent)
+	}
 }
 
 // handleLeave 处理用户离开
 func (c *Client) handleLeave() {
 	// 从用户服务中移除用户
-	user := c.hub.chatService.RemoveUser(c.socketID)
+	user, systemMessage := c.hub.chatService.RemoveUser(c.socketID)
 	if user != nil {
+		c.hub.setTyping(user.ID, false)
+
 		// 广播用户离开事件
-		userLeftEvent := models.UserLeftEvent{User: user}
+		userLeftEvent := models.UserLeftEvent{User: user, MessageID: systemMessage.ID}
 		c.hub.broadcastMessage("user_left", userLeftEvent)
 
 		// 广播用户列表更新
@@ -266,6 +812,15 @@ func (c *Client) handleLeave() {
 	c.conn.Close()
 }
 
+// handleTyping 处理输入状态，未加入或超出频率限制的更新直接丢弃
+func (c *Client) handleTyping(typing bool) {
+	user, allowed := c.hub.chatService.AllowTyping(c.socketID, typing)
+	if !allowed {
+		return
+	}
+	c.hub.setTyping(user.ID, typing)
+}
+
 // handlePing 处理ping消息
 func (c *Client) handlePing() {
 	c.sendMessage("pong", nil)
@@ -274,11 +829,12 @@ func (c *Client) handlePing() {
 // sendMessage 发送消息给客户端
 func (c *Client) sendMessage(messageType string, data interface{}) {
 	wsMessage := models.WebSocketMessage{
-		Type: messageType,
-		Data: data,
+		Type:      messageType,
+		Data:      data,
+		RequestID: c.requestID,
 	}
 
-	messageBytes, err := json.Marshal(wsMessage)
+	messageBytes, err := c.codec.Encode(&wsMessage)
 	if err != nil {
 		log.Printf("序列化消息失败: %v", err)
 		return
@@ -292,23 +848,102 @@ func (c *Client) sendMessage(messageType string, data interface{}) {
 	}
 }
 
-// sendError 发送错误消息
-func (c *Client) sendError(message string) {
-	c.sendMessage("error", models.ErrorEvent{Message: message})
+// decode 按连接的编码解析事件数据
+func (c *Client) decode(data []byte, v interface{}) error {
+	return c.codec.DecodeData(data, v)
 }
 
-// broadcastMessage 广播消息给所有客户端
+// sendError 发送业务错误，错误码从err中获取
+func (c *Client) sendError(err error) {
+	c.sendErrorCode(services.ErrorCode(err), err.Error())
+}
+
+// sendErrorCode 发送带错误码的错误，错误信息按客户端协商的语言返回
+func (c *Client) sendErrorCode(code, message string) {
+	c.sendMessage("error", models.ErrorEvent{
+		Code:    code,
+		Message: services.LocalizeError(code, message, c.locale),
+	})
+}
+
+// broadcastMessage 广播房间事件给所有已加入聊天室的客户端，不能在Run中调用
 func (h *Hub) broadcastMessage(messageType string, data interface{}) {
-	wsMessage := models.WebSocketMessage{
-		Type: messageType,
-		Data: data,
-	}
+	h.broadcast <- roomEvent{eventType: messageType, data: data}
+}
 
-	messageBytes, err := json.Marshal(wsMessage)
-	if err != nil {
-		log.Printf("序列化广播消息失败: %v", err)
+// setTyping 更新用户的输入状态，不能在Run中调用
+func (h *Hub) setTyping(userID string, typing bool) {
+	h.typing <- typingUpdate{userID: userID, typing: typing}
+}
+
+// sendToUser 发送消息给指定用户，用户不在线时忽略
+func (h *Hub) sendToUser(userID string, messageType string, data interface{}) {
+	user, exists := h.chatService.GetUserByID(userID)
+	if !exists {
 		return
 	}
 
-	h.broadcast <- messageBytes
+	h.direct <- directMessage{socketID: user.SocketID, message: newOutboundMessage(messageType, data, 0)}
+}
+
+// BroadcastNewMessage 广播新消息，通知被提及的用户并更新所属话题的汇总
+func (h *Hub) BroadcastNewMessage(message *models.Message) {
+	newMessageEvent := models.NewMessageEvent{Message: message}
+	h.broadcastMessage("new_message", newMessageEvent)
+	h.options.Bots.Notify(message)
+
+	// 通知被提及的用户
+	for _, userID := range message.Mentions {
+		mentionedEvent := models.MentionedEvent{
+			Message:        message,
+			UnreadMentions: h.chatService.GetUnreadMentions(userID).Count,
+		}
+		h.sendToUser(userID, "mentioned", mentionedEvent)
+	}
+
+	// 广播话题汇总更新
+	if message.ReplyTo != "" {
+		if parent, exists := h.chatService.GetMessage(message.ReplyTo); exists && parent.Thread != nil {
+			threadUpdatedEvent := models.ThreadUpdatedEvent{
+				MessageID: parent.ID,
+				Thread:    parent.Thread,
+			}
+			h.broadcastMessage("thread_updated", threadUpdatedEvent)
+		}
+	}
+}
+
+// broadcastRevision 广播消息修改引起的引用快照更新，并通知编辑后新提及的用户
+func (h *Hub) broadcastRevision(revision *services.Revision) {
+	if len(revision.QuotedBy) > 0 {
+		quoteUpdatedEvent := models.QuoteUpdatedEvent{
+			MessageIDs: revision.QuotedBy,
+			Quote:      revision.Quote,
+		}
+		h.broadcastMessage("quote_updated", quoteUpdatedEvent)
+	}
+
+	for _, userID := range revision.NewMentions {
+		mentionedEvent := models.MentionedEvent{
+			Message:        revision.Message,
+			UnreadMentions: h.chatService.GetUnreadMentions(userID).Count,
+		}
+		h.sendToUser(userID, "mentioned", mentionedEvent)
+	}
+}
+
+// BroadcastRoomUpdated 广播聊天室信息更新
+func (h *Hub) BroadcastRoomUpdated(room *models.Room) {
+	roomUpdatedEvent := models.RoomUpdatedEvent{Room: room}
+	h.broadcastMessage("room_updated", roomUpdatedEvent)
+}
+
+// BroadcastUserUpdated 广播用户资料更新
+func (h *Hub) BroadcastUserUpdated(user *models.User) {
+	userUpdatedEvent := models.UserUpdatedEvent{User: user}
+	h.broadcastMessage("user_updated", userUpdatedEvent)
+
+	// 广播用户列表更新
+	userListEvent := models.UserListEvent{Users: h.chatService.GetOnlineUsers()}
+	h.broadcastMessage("user_list", userListEvent)
 }
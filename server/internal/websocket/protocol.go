@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+)
+
+const (
+	// legacyProtocolVersion 未握手的连接使用的协议版本，未知事件和无法解析的消息会被忽略
+	legacyProtocolVersion = 1
+
+	// ProtocolVersion 当前协议版本，未知事件和无法解析的消息会返回错误
+	ProtocolVersion = 2
+)
+
+// supportedVersions 服务端支持的协议版本，按从新到旧排列
+var supportedVersions = []int{ProtocolVersion, legacyProtocolVersion}
+
+// serverFeatures 服务端提供的功能，客户端可据此判断能否使用相应事件
+var serverFeatures = []string{
+	"ack",
+	"bots",
+	"code_blocks",
+	"images",
+	"mentions",
+	"pins",
+	"reactions",
+	"read_markers",
+	"reports",
+	"resume",
+	"threads",
+	"typing",
+}
+
+// eventFeatures 属于某项功能的推送事件，只发给启用了该功能的连接
+var eventFeatures = map[string]string{
+	"mentioned":        "mentions",
+	"message_seen":     "read_markers",
+	"quote_updated":    "threads",
+	"reaction_updated": "reactions",
+	"thread_updated":   "threads",
+	"typing":           "typing",
+}
+
+// requestFeatures 属于某项功能的请求，连接未启用该功能时返回FEATURE_DISABLED
+var requestFeatures = map[string]string{
+	"clear_mentions": "mentions",
+	"get_mentions":   "mentions",
+	"get_thread":     "threads",
+	"mark_read":      "read_markers",
+	"pin_message":    "pins",
+	"react":          "reactions",
+	"report_message": "reports",
+	"typing_start":   "typing",
+	"typing_stop":    "typing",
+	"unpin_message":  "pins",
+}
+
+// featureSet 连接启用的功能，创建后不再修改，nil表示未握手，启用全部功能
+type featureSet map[string]bool
+
+// newFeatureSet 根据协商结果创建功能集合
+func newFeatureSet(features []string) featureSet {
+	set := make(featureSet, len(features))
+	for _, feature := range features {
+		set[feature] = true
+	}
+	return set
+}
+
+// allows 是否启用了功能
+func (f featureSet) allows(feature string) bool {
+	return f == nil || f[feature]
+}
+
+// allowsEvent 是否向连接推送该类型的事件，不属于任何功能的事件总是推送
+func (f featureSet) allowsEvent(eventType string) bool {
+	feature, gated := eventFeatures[eventType]
+	return !gated || f.allows(feature)
+}
+
+// allowsRequest 是否接受该类型的请求，返回请求所属的功能
+func (f featureSet) allowsRequest(eventType string) (string, bool) {
+	feature, gated := requestFeatures[eventType]
+	return feature, !gated || f.allows(feature)
+}
+
+// negotiateVersion 选择双方都支持的最高版本，客户端未声明版本时使用当前版本
+func negotiateVersion(versions []int) (int, bool) {
+	if len(versions) == 0 {
+		return ProtocolVersion, true
+	}
+	for _, supported := range supportedVersions {
+		for _, version := range versions {
+			if version == supported {
+				return supported, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// negotiateFeatures 返回双方都支持的功能，客户端未声明时启用全部功能
+func negotiateFeatures(requested []string) []string {
+	if len(requested) == 0 {
+		return serverFeatures
+	}
+
+	wanted := make(map[string]bool, len(requested))
+	for _, feature := range requested {
+		wanted[feature] = true
+	}
+	features := make([]string, 0, len(requested))
+	for _, feature := range serverFeatures {
+		if wanted[feature] {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
+
+// handleHello 处理握手，协商协议版本、功能和错误信息语言
+// 启用的功能在加入聊天室时生效，因此握手需要在加入之前完成
+func (c *Client) handleHello(data []byte) {
+	var helloReq models.HelloRequest
+	if err := c.decode(data, &helloReq); err != nil {
+		c.sendErrorCode(services.CodeInvalidRequest, "无效的握手请求")
+		return
+	}
+	if _, joined := c.hub.chatService.GetUser(c.socketID); joined {
+		c.sendErrorCode(services.CodeInvalidRequest, "请在加入聊天室之前握手")
+		return
+	}
+
+	version, ok := negotiateVersion(helloReq.Versions)
+	if !ok {
+		c.sendErrorCode(services.CodeUnsupportedVersion, "不支持客户端的协议版本")
+		return
+	}
+
+	features := negotiateFeatures(helloReq.Features)
+	c.protocolVersion = version
+	c.locale = services.NormalizeLocale(helloReq.Locale)
+	c.features = newFeatureSet(features)
+	c.sendMessage("hello", models.HelloResponse{
+		Version:           version,
+		SupportedVersions: supportedVersions,
+		Features:          features,
+		Locale:            c.locale,
+	})
+}
@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pixel-chat-server/internal/models"
+	"pixel-chat-server/internal/services"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestNegotiateFeatures(t *testing.T) {
+	if got := negotiateFeatures(nil); !reflect.DeepEqual(got, serverFeatures) {
+		t.Errorf("negotiateFeatures(nil) = %v, want all server features", got)
+	}
+	got := negotiateFeatures([]string{"typing", "unknown", "reactions"})
+	if want := []string{"reactions", "typing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("negotiateFeatures = %v, want %v", got, want)
+	}
+}
+
+func TestFeatureSet(t *testing.T) {
+	var legacy featureSet
+	if !legacy.allowsEvent("typing") || !legacy.allows("reactions") {
+		t.Error("clients without a handshake should receive every feature")
+	}
+
+	features := newFeatureSet([]string{"reactions"})
+	if !features.allowsEvent("reaction_updated") || features.allowsEvent("typing") {
+		t.Error("events should follow the negotiated features")
+	}
+	if !features.allowsEvent("new_message") {
+		t.Error("events outside any feature should always be delivered")
+	}
+	if feature, ok := features.allowsRequest("typing_start"); ok || feature != "typing" {
+		t.Errorf("allowsRequest(typing_start) = %q, %v, want typing, false", feature, ok)
+	}
+	if _, ok := features.allowsRequest("react"); !ok {
+		t.Error("react should be allowed with reactions enabled")
+	}
+
+	// 每个受控的事件和请求都属于服务端提供的功能
+	for name, feature := range eventFeatures {
+		if !newFeatureSet(serverFeatures).allows(feature) {
+			t.Errorf("event %s belongs to unknown feature %s", name, feature)
+		}
+	}
+	for name, feature := range requestFeatures {
+		if !newFeatureSet(serverFeatures).allows(feature) {
+			t.Errorf("request %s belongs to unknown feature %s", name, feature)
+		}
+	}
+}
+
+// testConn 连接测试Hub的JSON客户端
+type testConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// newTestHub 启动使用内存存储的Hub，返回WebSocket地址
+func newTestHub(t *testing.T) string {
+	t.Helper()
+	uploadService, err := services.NewUploadService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outgoing, err := services.NewOutgoingWebhookService(services.OutgoingWebhookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatService := services.NewChatService(services.NewUserService(), services.NewMessageService(),
+		services.NewTripcodeService("test-pepper"), services.NewMentionService(), uploadService,
+		services.NewReadMarkerService(), services.NewRoomService(), services.NewAPITokenService(),
+		services.NewIncomingWebhookService(), outgoing, services.NewBotService(), services.ChatOptions{})
+	hub := NewHubWith(chatService, HubOptions{})
+	go hub.Run()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.HandleWebSocket(conn, uuid.New().String())
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dialTestConn 连接Hub
+func dialTestConn(t *testing.T, url string) *testConn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+// send 发送事件
+func (c *testConn) send(eventType string, data interface{}) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(models.WebSocketMessage{Type: eventType, Data: data}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next 读取事件直到类型匹配，返回事件数据和跳过的事件类型
+func (c *testConn) next(eventType string) (json.RawMessage, []string) {
+	c.t.Helper()
+	var skipped []string
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := c.conn.ReadJSON(&message); err != nil {
+			c.t.Fatalf("waiting for %s: %v", eventType, err)
+		}
+		if message.Type == eventType {
+			return message.Data, skipped
+		}
+		skipped = append(skipped, message.Type)
+	}
+}
+
+func TestHandshakeFeaturesGateEvents(t *testing.T) {
+	url := newTestHub(t)
+
+	alice := dialTestConn(t, url)
+	alice.send("join", models.JoinRequest{Nickname: "alice"})
+	alice.next("joined")
+
+	// bob只启用ack，不接收表情回应，也不能发送回应
+	bob := dialTestConn(t, url)
+	bob.send("hello", models.HelloRequest{Features: []string{"ack"}})
+	data, _ := bob.next("hello")
+	var hello models.HelloResponse
+	if err := json.Unmarshal(data, &hello); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hello.Features, []string{"ack"}) {
+		t.Fatalf("features = %v, want [ack]", hello.Features)
+	}
+	bob.send("join", models.JoinRequest{Nickname: "bob"})
+	bob.next("joined")
+
+	alice.send("send_message", models.SendMessageRequest{Content: "hello"})
+	data, _ = bob.next("new_message")
+	var event models.NewMessageEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	bob.send("react", models.ReactRequest{MessageID: event.Message.ID, Emoji: "smile"})
+	data, _ = bob.next("error")
+	var errorEvent models.ErrorEvent
+	if err := json.Unmarshal(data, &errorEvent); err != nil {
+		t.Fatal(err)
+	}
+	if errorEvent.Code != services.CodeFeatureDisabled {
+		t.Errorf("react error = %+v, want %s", errorEvent, services.CodeFeatureDisabled)
+	}
+
+	// alice的回应先于之后的消息广播，bob只会收到消息
+	alice.send("react", models.ReactRequest{MessageID: event.Message.ID, Emoji: "smile"})
+	alice.next("reaction_updated")
+	alice.send("send_message", models.SendMessageRequest{Content: "after"})
+	_, skipped := bob.next("new_message")
+	for _, eventType := range skipped {
+		if eventType == "reaction_updated" {
+			t.Error("reaction_updated was sent to a client without the reactions feature")
+		}
+	}
+
+	// 加入后不能再修改启用的功能
+	bob.send("hello", models.HelloRequest{})
+	data, _ = bob.next("error")
+	if err := json.Unmarshal(data, &errorEvent); err != nil {
+		t.Fatal(err)
+	}
+	if errorEvent.Code != services.CodeInvalidRequest {
+		t.Errorf("hello after join error = %+v, want %s", errorEvent, services.CodeInvalidRequest)
+	}
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"pixel-chat-server/internal/models"
+)
+
+// streamBufferSize 每个只读订阅缓冲的事件数，消费过慢的订阅会被关闭
+const streamBufferSize = 256
+
+// StreamEvent 推送给只读订阅者的房间事件
+type StreamEvent struct {
+	Seq  uint64 // 房间事件序号，输入状态等临时事件为0
+	Type string
+	Data []byte // 与WebSocket JSON编码相同的完整消息
+}
+
+// Stream 房间事件的只读订阅，用于SSE等不能保持WebSocket连接的客户端
+type Stream struct {
+	events  chan StreamEvent
+	lastSeq uint64
+}
+
+// Events 返回事件通道，订阅取消或消费过慢时通道关闭
+func (s *Stream) Events() <-chan StreamEvent {
+	return s.events
+}
+
+// Subscribe 订阅房间事件，lastSeq之后错过的事件会先补发，已被淘汰时先推送resync_required
+func (h *Hub) Subscribe(lastSeq uint64) *Stream {
+	stream := &Stream{
+		events:  make(chan StreamEvent, streamBufferSize),
+		lastSeq: lastSeq,
+	}
+	h.streamSubscribe <- stream
+	return stream
+}
+
+// Unsubscribe 取消订阅，可重复调用
+func (h *Hub) Unsubscribe(stream *Stream) {
+	h.streamUnsubscribe <- stream
+}
+
+// Seq 返回最新的房间事件序号
+func (h *Hub) Seq() uint64 {
+	return h.seq.Load()
+}
+
+// subscribeStream 补发错过的事件后开始推送房间事件，只能在Run中调用
+func (h *Hub) subscribeStream(stream *Stream) {
+	currentSeq := h.seq.Load()
+	messages, ok := h.events.since(stream.lastSeq, currentSeq)
+	if !ok {
+		messages = []*outboundMessage{newOutboundMessage("resync_required", models.ResyncRequiredEvent{
+			LastSeq:    stream.lastSeq,
+			CurrentSeq: currentSeq,
+		}, 0)}
+	}
+
+	h.streams[stream] = true
+	for _, message := range messages {
+		h.sendToStream(stream, message)
+	}
+}
+
+// unsubscribeStream 取消订阅并关闭事件通道，只能在Run中调用
+func (h *Hub) unsubscribeStream(stream *Stream) {
+	if _, ok := h.streams[stream]; ok {
+		delete(h.streams, stream)
+		close(stream.events)
+	}
+}
+
+// sendToStream 推送事件给只读订阅者，缓冲已满时关闭订阅，只能在Run中调用
+func (h *Hub) sendToStream(stream *Stream, message *outboundMessage) {
+	if _, ok := h.streams[stream]; !ok {
+		return
+	}
+
+	frame := message.frame(JSONCodec)
+	if frame == nil {
+		return
+	}
+
+	select {
+	case stream.events <- StreamEvent{Seq: message.message.Seq, Type: message.message.Type, Data: frame}:
+	default:
+		h.unsubscribeStream(stream)
+	}
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// typingTimeout 未收到typing_stop时输入状态的保持时间，客户端输入期间应定期重发typing_start
+	typingTimeout = 6 * time.Second
+
+	// typingFlushInterval 合并输入状态变化后广播的间隔，同一间隔内的多次变化只广播一次
+	typingFlushInterval = 500 * time.Millisecond
+)
+
+// typingUpdate 用户输入状态变化
+type typingUpdate struct {
+	userID string
+	typing bool
+}
+
+// typingState 房间内正在输入的用户，只能在Hub.Run中访问
+type typingState struct {
+	expires map[string]time.Time // 用户ID -> 输入状态过期时间
+	dirty   bool
+}
+
+func newTypingState() *typingState {
+	return &typingState{
+		expires: make(map[string]time.Time),
+	}
+}
+
+// set 更新用户的输入状态，重复的typing_start只延长过期时间，不触发广播
+func (t *typingState) set(userID string, typing bool, now time.Time) {
+	_, active := t.expires[userID]
+	if typing {
+		t.expires[userID] = now.Add(typingTimeout)
+		t.dirty = t.dirty || !active
+		return
+	}
+	if active {
+		delete(t.expires, userID)
+		t.dirty = true
+	}
+}
+
+// flush 清理过期的输入状态，有变化时返回当前正在输入的用户ID
+func (t *typingState) flush(now time.Time) ([]string, bool) {
+	for userID, expires := range t.expires {
+		if !now.Before(expires) {
+			delete(t.expires, userID)
+			t.dirty = true
+		}
+	}
+	if !t.dirty {
+		return nil, false
+	}
+	t.dirty = false
+
+	userIDs := make([]string, 0, len(t.expires))
+	for userID := range t.expires {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+	return userIDs, true
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"log"
 	"net/http"
+	"pixel-chat-server/internal/bots"
 	"pixel-chat-server/internal/config"
 	"pixel-chat-server/internal/handlers"
 	"pixel-chat-server/internal/services"
 	"pixel-chat-server/internal/websocket"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -38,12 +41,55 @@ func main() {
 
 	// 初始化服务
 	userService := services.NewUserService()
-	messageService := services.NewMessageService()
-	chatService := services.NewChatService(userService, messageService)
+	messageService := services.NewMessageServiceWith(cfg.MaxMessagesHistory, cfg.MaxMessageLength, cfg.MaxCodeLength)
+	tripcodeService := services.NewTripcodeService(cfg.TripcodePepper)
+	mentionService := services.NewMentionService()
+	readMarkerService := services.NewReadMarkerService()
+	roomService := services.NewRoomService()
+	apiTokenService := services.NewAPITokenService()
+	incomingWebhookService := services.NewIncomingWebhookService()
+	botService := services.NewBotService()
+	uploadService, err := services.NewUploadService(cfg.UploadDir)
+	if err != nil {
+		log.Fatal("初始化上传服务失败:", err)
+	}
+	outgoingWebhookService, err := services.NewOutgoingWebhookService(services.OutgoingWebhookOptions{
+		StorePath: cfg.WebhookStore,
+	})
+	if err != nil {
+		log.Fatal("初始化传出webhook服务失败:", err)
+	}
+	go outgoingWebhookService.Run()
+	chatService := services.NewChatService(userService, messageService, tripcodeService, mentionService, uploadService, readMarkerService, roomService, apiTokenService, incomingWebhookService, outgoingWebhookService, botService, services.ChatOptions{
+		AdminToken: cfg.AdminToken,
+		EditWindow: time.Duration(cfg.EditWindowSeconds) * time.Second,
+		SendLimit:  cfg.SendRateLimit,
+	})
+
+	// 注册内置机器人
+	botRegistry := bots.NewRegistry(chatService)
+	for _, name := range strings.Split(cfg.BuiltinBots, ",") {
+		if name = strings.TrimSpace(name); name == "" || name == "none" {
+			continue
+		}
+		bot, err := bots.Builtin(name)
+		if err == nil {
+			err = botRegistry.Register(bot)
+		}
+		if err != nil {
+			log.Fatal("注册内置机器人失败:", err)
+		}
+	}
 
 	// 初始化WebSocket Hub
-	hub := websocket.NewHub(chatService)
+	hub := websocket.NewHubWith(chatService, websocket.HubOptions{
+		Compression:          cfg.WSCompression,
+		CompressionLevel:     cfg.WSCompressionLevel,
+		CompressionThreshold: cfg.WSCompressionThreshold,
+		Bots:                 botRegistry,
+	})
 	go hub.Run()
+	go botRegistry.Run(hub.BroadcastNewMessage)
 
 	// 初始化处理器
 	handlers := handlers.NewHandlers(chatService, hub)
@@ -71,7 +117,40 @@ func setupRoutes(r *gin.Engine, handlers *handlers.Handlers) {
 	{
 		api.GET("/stats", handlers.GetStats)
 		api.GET("/users", handlers.GetUsers)
+		api.GET("/users/:id", handlers.GetUserProfile)
+		api.GET("/profiles", handlers.GetProfiles)
 		api.GET("/messages", handlers.GetMessages)
+		api.POST("/messages", handlers.PostMessage)
+		api.GET("/messages/:id/thread", handlers.GetThread)
+		api.GET("/messages/:id/history", handlers.GetMessageHistory)
+		api.GET("/room", handlers.GetRoom)
+		api.GET("/stream", handlers.Stream)
+		api.PUT("/room", handlers.UpdateRoom)
+		api.POST("/room/pins", handlers.PinMessage)
+		api.DELETE("/room/pins/:id", handlers.UnpinMessage)
+		api.POST("/rooms/:room/messages", handlers.PostRoomMessage)
+		api.GET("/tokens", handlers.ListAPITokens)
+		api.POST("/tokens", handlers.CreateAPIToken)
+		api.DELETE("/tokens/:id", handlers.RevokeAPIToken)
+		api.GET("/webhooks/incoming", handlers.ListIncomingWebhooks)
+		api.POST("/webhooks/incoming", handlers.CreateIncomingWebhook)
+		api.DELETE("/webhooks/incoming/:id", handlers.DeleteIncomingWebhook)
+		api.GET("/bots", handlers.ListBots)
+		api.POST("/bots", handlers.CreateBot)
+		api.DELETE("/bots/:id", handlers.DeleteBot)
+		api.GET("/webhooks/outgoing", handlers.ListOutgoingWebhooks)
+		api.POST("/webhooks/outgoing", handlers.CreateOutgoingWebhook)
+		api.PUT("/webhooks/outgoing/:id", handlers.UpdateOutgoingWebhook)
+		api.DELETE("/webhooks/outgoing/:id", handlers.DeleteOutgoingWebhook)
+		api.GET("/webhooks/outgoing/:id/deliveries", handlers.GetWebhookDeliveries)
+		api.POST("/webhooks/outgoing/:id/ping", handlers.PingOutgoingWebhook)
+		api.POST("/hooks/:id/:secret", handlers.PostWebhookMessage)
+		api.GET("/emoji", handlers.GetEmoji)
+		api.GET("/avatars/:file", handlers.GetAvatar)
+		api.PUT("/me/avatar", handlers.SetMyAvatar)
+		api.GET("/me/unread", handlers.GetMyUnread)
+		api.POST("/uploads", handlers.UploadImage)
+		api.GET("/uploads/:file", handlers.GetUpload)
 	}
 
 	// WebSocket路由